@@ -14,6 +14,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 	"text/tabwriter"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
@@ -27,8 +28,9 @@ import (
 // Subcommands: create; Usage: availability-zone create [<options>]
 //              delete; Usage: availability-zone delete <id>
 //              list;   Usage: availability-zone list
-//              show;   Usage: availability-zone show <id>
+//              show;   Usage: availability-zone show <id> [<options>]
 //              tasks;  Usage: availability-zone tasks <id> [<options>]
+//              rebalance; Usage: availability-zone rebalance [<options>]
 func GetAvailabilityZonesCommand() cli.Command {
 	command := cli.Command{
 		Name:  "availability-zone",
@@ -73,6 +75,12 @@ func GetAvailabilityZonesCommand() cli.Command {
 			{
 				Name:  "show",
 				Usage: "Show specified availability-zone",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "hosts",
+						Usage: "List the hosts of the availability-zone and their VM count",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := showAvailabilityZone(c, os.Stdout)
 					if err != nil {
@@ -96,6 +104,35 @@ func GetAvailabilityZonesCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "rebalance",
+				Usage: "Move hosts from one availability-zone to another",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "from",
+						Usage: "Availability-zone to move the hosts from, name or id",
+					},
+					cli.StringFlag{
+						Name:  "to",
+						Usage: "Availability-zone to move the hosts to, name or id",
+					},
+					cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "Number of hosts to move, the hosts with the fewest VMs are moved first",
+					},
+					cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "List the hosts that would be moved without moving them",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := rebalanceAvailabilityZones(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 		},
 	}
 	return command
@@ -154,7 +191,7 @@ func createAvailabilityZone(c *cli.Context, w io.Writer) error {
 
 // Retrieves availability zone against specified id.
 func showAvailabilityZone(c *cli.Context, w io.Writer) error {
-	err := checkArgNum(c.Args(), 1, "availability-zone show <id>")
+	err := checkArgNum(c.Args(), 1, "availability-zone show <id> [<options>]")
 	if err != nil {
 		return err
 	}
@@ -165,20 +202,53 @@ func showAvailabilityZone(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveAvailabilityZoneID(id)
+	if err != nil {
+		return err
+	}
+
 	zone, err := client.Esxclient.AvailabilityZones.Get(id)
 	if err != nil {
 		return err
 	}
 
+	var members []availabilityZoneHost
+	if c.Bool("hosts") {
+		members, err = getAvailabilityZoneHosts(zone.ID)
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.GlobalIsSet("non-interactive") {
 		fmt.Printf("%s\t%s\t%s\t%s\n", zone.ID, zone.Name, zone.Kind, zone.State)
+		for _, member := range members {
+			fmt.Printf("%s\t%s\t%s\t%d\n", member.ID, member.Address, member.State, member.VMs)
+		}
 	} else if utils.NeedsFormatting(c) {
-		utils.FormatObject(zone, w, c)
+		if c.Bool("hosts") {
+			utils.FormatObject(availabilityZoneWithHosts{*zone, members}, w, c)
+		} else {
+			utils.FormatObject(zone, w, c)
+		}
 	} else {
 		fmt.Printf("AvailabilityZone ID: %s\n", zone.ID)
 		fmt.Printf("  Name:        %s\n", zone.Name)
 		fmt.Printf("  Kind:        %s\n", zone.Kind)
 		fmt.Printf("  State:       %s\n", zone.State)
+		if c.Bool("hosts") {
+			fmt.Printf("  Hosts:\n")
+			w := new(tabwriter.Writer)
+			w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "    ID\tIP\tState\tVMs\n")
+			for _, member := range members {
+				fmt.Fprintf(w, "    %s\t%s\t%s\t%d\n", member.ID, member.Address, member.State, member.VMs)
+			}
+			err = w.Flush()
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -237,6 +307,11 @@ func deleteAvailabilityZone(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveAvailabilityZoneID(id)
+	if err != nil {
+		return err
+	}
+
 	deleteTask, err := client.Esxclient.AvailabilityZones.Delete(id)
 	if err != nil {
 		return err
@@ -268,6 +343,11 @@ func getAvailabilityZoneTasks(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveAvailabilityZoneID(id)
+	if err != nil {
+		return err
+	}
+
 	taskList, err := client.Esxclient.AvailabilityZones.GetTasks(id, options)
 	if err != nil {
 		return err
@@ -279,3 +359,157 @@ func getAvailabilityZoneTasks(c *cli.Context, w io.Writer) error {
 	}
 	return nil
 }
+
+// A host of an availability zone
+type availabilityZoneHost struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	State   string `json:"state"`
+	VMs     int    `json:"vms"`
+}
+
+// Sorts the hosts with the fewest VMs first
+type hostsByVMsSorter []availabilityZoneHost
+
+func (h hostsByVMsSorter) Len() int      { return len(h) }
+func (h hostsByVMsSorter) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h hostsByVMsSorter) Less(i, j int) bool {
+	if h[i].VMs != h[j].VMs {
+		return h[i].VMs < h[j].VMs
+	}
+	return h[i].Address < h[j].Address
+}
+
+type availabilityZoneWithHosts struct {
+	photon.AvailabilityZone
+	Hosts []availabilityZoneHost `json:"hosts"`
+}
+
+// Retrieves the hosts of an availability zone with the number of VMs on each of them
+func getAvailabilityZoneHosts(id string) ([]availabilityZoneHost, error) {
+	hosts, err := client.Esxclient.Hosts.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var zoneHosts []photon.Host
+	for _, host := range hosts.Items {
+		if host.AvailabilityZone == id {
+			zoneHosts = append(zoneHosts, host)
+		}
+	}
+
+	hostVMs, err := getHostVMs(zoneHosts)
+	if err != nil {
+		return nil, err
+	}
+	members := []availabilityZoneHost{}
+	for i, host := range zoneHosts {
+		members = append(members, availabilityZoneHost{host.ID, host.Address, host.State, len(hostVMs[i])})
+	}
+	return members, nil
+}
+
+// Moves --count hosts from one availability zone to another, starting with the hosts
+// that have the fewest VMs
+func rebalanceAvailabilityZones(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 0, "availability-zone rebalance --from <zone> --to <zone> [<options>]")
+	if err != nil {
+		return err
+	}
+	if len(c.String("from")) == 0 || len(c.String("to")) == 0 {
+		return fmt.Errorf("Please provide the availability zones with --from and --to")
+	}
+	count := c.Int("count")
+	if count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	isScripting := utils.IsNonInteractive(c)
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	from, err := findAvailabilityZone(c.String("from"))
+	if err != nil {
+		return err
+	}
+	to, err := findAvailabilityZone(c.String("to"))
+	if err != nil {
+		return err
+	}
+	if from.ID == to.ID {
+		return fmt.Errorf("--from and --to are the same availability zone")
+	}
+
+	members, err := getAvailabilityZoneHosts(from.ID)
+	if err != nil {
+		return err
+	}
+	if len(members) < count {
+		return fmt.Errorf("Availability zone %s only has %d hosts", from.Name, len(members))
+	}
+	sort.Stable(hostsByVMsSorter(members))
+	members = members[:count]
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, member := range members {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", member.ID, member.Address, member.VMs, from.Name, to.Name)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(members, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Host ID\tIP\tVMs\tFrom\tTo\n")
+		for _, member := range members {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", member.ID, member.Address, member.VMs, from.Name, to.Name)
+		}
+		err = tw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+	if !confirmed(isScripting) {
+		fmt.Fprintln(w, "OK. Canceled")
+		return nil
+	}
+
+	for _, member := range members {
+		operation := &photon.HostSetAvailabilityZoneOperation{AvailabilityZoneId: to.ID}
+		err = waitForTask(client.Esxclient.Hosts.SetAvailabilityZone(member.ID, operation))
+		if err != nil {
+			return fmt.Errorf("Moving host %s failed: %s", member.Address, err)
+		}
+		if !isScripting {
+			fmt.Fprintf(w, "Moved host %s to availability zone %s\n", member.Address, to.Name)
+		}
+	}
+	return nil
+}
+
+// Finds an availability zone by ID, name or ID prefix
+func findAvailabilityZone(nameOrID string) (*photon.AvailabilityZone, error) {
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []resolveCandidate
+	for _, zone := range zones.Items {
+		candidates = append(candidates, resolveCandidate{ID: zone.ID, Name: zone.Name})
+	}
+	id, err := resolveCandidates("availability zone", nameOrID, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones.Items {
+		if zone.ID == id {
+			return &zone, nil
+		}
+	}
+	return nil, fmt.Errorf("Availability zone '%s' does not exist", nameOrID)
+}
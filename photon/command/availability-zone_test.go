@@ -61,6 +61,7 @@ func TestCreateDeleteAvailabilityZone(t *testing.T) {
 		mocks.CreateResponder(200, string(taskresponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/availabilityzones", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -133,6 +134,7 @@ func TestShowAvailabilityZone(t *testing.T) {
 		mocks.CreateResponder(200, string(response[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/availabilityzones", "availabilityzone_id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -272,6 +274,7 @@ func TestAvailabilityZoneTasks(t *testing.T) {
 
 	defer server.Close()
 
+	registerResolveList(server.URL+"/availabilityzones", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -287,3 +290,161 @@ func TestAvailabilityZoneTasks(t *testing.T) {
 		t.Error("Not expecting retrieving availabilityzone tasks to fail: " + err.Error())
 	}
 }
+
+func registerRebalanceResponders(t *testing.T, serverURL string) {
+	zonesResponse, err := json.Marshal(MockAvailZonePage{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}, {ID: "zone-2", Name: "az2"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	zoneResponse, err := json.Marshal(photon.AvailabilityZone{ID: "zone-1", Name: "az1", Kind: "availability-zone",
+		State: "READY"})
+	if err != nil {
+		t.Error("Not expecting error serializing availability zone")
+	}
+	hosts := []photon.Host{
+		{ID: "host-1", Address: "10.0.0.1", State: "READY", AvailabilityZone: "zone-1"},
+		{ID: "host-2", Address: "10.0.0.2", State: "READY", AvailabilityZone: "zone-1"},
+		{ID: "host-3", Address: "10.0.0.3", State: "READY", AvailabilityZone: "zone-2"},
+	}
+	hostsResponse, err := json.Marshal(MockHostsPage{Items: hosts})
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	hostVMs := map[string]int{"host-1": 2, "host-2": 1, "host-3": 0}
+
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/availabilityzones/zone-1",
+		mocks.CreateResponder(200, string(zoneResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	for _, host := range hosts {
+		vms := photon.VMs{}
+		for i := 0; i < hostVMs[host.ID]; i++ {
+			vms.Items = append(vms.Items, photon.VM{ID: host.ID + "-vm", State: "STARTED"})
+		}
+		vmsResponse, err := json.Marshal(vms)
+		if err != nil {
+			t.Error("Not expecting error serializing vms")
+		}
+		mocks.RegisterResponder(
+			"GET",
+			serverURL+"/hosts/"+host.ID+"/vms",
+			mocks.CreateResponder(200, string(vmsResponse[:])))
+	}
+}
+
+func TestShowAvailabilityZoneHosts(t *testing.T) {
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerRebalanceResponders(t, server.URL)
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.String("output", "json", "output")
+	err := globalSet.Parse([]string{"--output", "json"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("hosts", true, "hosts")
+	err = set.Parse([]string{"zone-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = showAvailabilityZone(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting show availability zone to fail: ", err)
+	}
+	var zone availabilityZoneWithHosts
+	err = json.Unmarshal(buf.Bytes(), &zone)
+	if err != nil {
+		t.Error("Not expecting error unmarshalling the availability zone: ", err)
+	}
+	if zone.ID != "zone-1" || len(zone.Hosts) != 2 || zone.Hosts[0].ID != "host-1" || zone.Hosts[0].VMs != 2 {
+		t.Errorf("Unexpected availability zone: %+v", zone)
+	}
+}
+
+func TestRebalanceAvailabilityZones(t *testing.T) {
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerRebalanceResponders(t, server.URL)
+
+	moveTask := photon.Task{ID: "move-task", State: "COMPLETED", Entity: photon.Entity{ID: "host-2"}}
+	moveResponse, err := json.Marshal(moveTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	moved := 0
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/hosts/host-2/set_availability_zone",
+		func(req *http.Request) (*http.Response, error) {
+			moved++
+			return mocks.CreateResponder(200, string(moveResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/move-task",
+		mocks.CreateResponder(200, string(moveResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	newContext := func(count int, dryRun bool) *cli.Context {
+		set := flag.NewFlagSet("test", 0)
+		set.String("from", "az1", "from")
+		set.String("to", "zone-2", "to")
+		set.Int("count", count, "count")
+		set.Bool("dry-run", dryRun, "dry run")
+		return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	}
+
+	// The host with the fewest VMs is picked
+	var buf bytes.Buffer
+	err = rebalanceAvailabilityZones(newContext(1, true), &buf)
+	if err != nil {
+		t.Error("Not expecting rebalance dry run to fail: ", err)
+	}
+	if buf.String() != "host-2\t10.0.0.2\t1\taz1\taz2\n" {
+		t.Errorf("Unexpected rebalance plan: %s", buf.String())
+	}
+	if moved != 0 {
+		t.Error("Not expecting a dry run to move hosts")
+	}
+
+	buf.Reset()
+	err = rebalanceAvailabilityZones(newContext(1, false), &buf)
+	if err != nil {
+		t.Error("Not expecting rebalance to fail: ", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected one host to be moved, moved %d", moved)
+	}
+
+	err = rebalanceAvailabilityZones(newContext(3, true), &buf)
+	if err == nil {
+		t.Error("Expected rebalance to fail when the zone does not have enough hosts")
+	}
+}
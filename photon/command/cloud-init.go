@@ -0,0 +1,223 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/gopkg.in/yaml.v2"
+	"github.com/vmware/photon-controller-cli/photon/client"
+)
+
+// Name of the ISO attached to VMs customized by cloud-init
+const cloudInitISOName = "cloud-init.iso"
+
+// Guest customization of the VMs created by 'vm create'
+type cloudInitOptions struct {
+	datasource    string
+	userData      []byte
+	networkConfig []byte
+	sshKeys       []string
+	hostname      string
+	detachISO     bool
+}
+
+// meta-data of the NoCloud datasource
+type noCloudMetaData struct {
+	InstanceID    string   `yaml:"instance-id"`
+	LocalHostname string   `yaml:"local-hostname,omitempty"`
+	PublicKeys    []string `yaml:"public-keys,omitempty"`
+}
+
+// openstack/latest/meta_data.json of the ConfigDrive datasource
+type configDriveMetaData struct {
+	UUID       string            `json:"uuid"`
+	Hostname   string            `json:"hostname,omitempty"`
+	PublicKeys map[string]string `json:"public_keys,omitempty"`
+}
+
+// Flags of 'vm create' customizing the guest with cloud-init
+func cloudInitFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "user-data",
+			Usage: "cloud-init user data file, e.g. a #cloud-config file",
+		},
+		cli.StringSliceFlag{
+			Name:  "ssh-key",
+			Value: &cli.StringSlice{},
+			Usage: "SSH public key file authorized in the guest, can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "hostname",
+			Usage: "Host name of the guest, the VM name by default",
+		},
+		cli.StringFlag{
+			Name:  "network-config",
+			Usage: "cloud-init network configuration file",
+		},
+		cli.StringFlag{
+			Name:  "cloud-init-datasource",
+			Value: "nocloud",
+			Usage: "Format of the cloud-init ISO: nocloud or configdrive",
+		},
+		cli.BoolFlag{
+			Name:  "detach-iso",
+			Usage: "Detach the cloud-init ISO once the VM reports an IP address",
+		},
+	}
+}
+
+// Reads the cloud-init flags. Returns nil when the guest is not customized.
+func getCloudInitOptions(c *cli.Context) (*cloudInitOptions, error) {
+	options := &cloudInitOptions{
+		datasource: c.String("cloud-init-datasource"),
+		hostname:   c.String("hostname"),
+		detachISO:  c.Bool("detach-iso"),
+	}
+	if len(c.String("user-data")) == 0 && len(c.StringSlice("ssh-key")) == 0 &&
+		len(options.hostname) == 0 && len(c.String("network-config")) == 0 {
+		if options.detachISO {
+			return nil, errors.New("--detach-iso needs --user-data, --ssh-key, --hostname or --network-config")
+		}
+		return nil, nil
+	}
+	if options.datasource != "nocloud" && options.datasource != "configdrive" {
+		return nil, fmt.Errorf("Invalid --cloud-init-datasource '%s', should be nocloud or configdrive", options.datasource)
+	}
+
+	var err error
+	if len(c.String("user-data")) != 0 {
+		options.userData, err = ioutil.ReadFile(expandHomeDir(c.String("user-data")))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.String("network-config")) != 0 {
+		options.networkConfig, err = ioutil.ReadFile(expandHomeDir(c.String("network-config")))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range c.StringSlice("ssh-key") {
+		key, err := ioutil.ReadFile(expandHomeDir(path))
+		if err != nil {
+			return nil, err
+		}
+		options.sshKeys = append(options.sshKeys, strings.TrimSpace(string(key)))
+	}
+	return options, nil
+}
+
+// Shells do not expand the ~ of --flag=~/path
+func expandHomeDir(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
+
+// Writes the cloud-init ISO of a VM. The instance ID is the VM ID, so that cloud-init
+// runs again in the clones of the VM.
+func writeCloudInitISO(w io.Writer, options *cloudInitOptions, id string, hostname string) error {
+	var files []isoFile
+	var volumeID string
+	if options.datasource == "configdrive" {
+		volumeID = "config-2"
+		metaData := configDriveMetaData{UUID: id, Hostname: hostname}
+		for i, key := range options.sshKeys {
+			if metaData.PublicKeys == nil {
+				metaData.PublicKeys = make(map[string]string)
+			}
+			metaData.PublicKeys[fmt.Sprintf("key-%d", i+1)] = key
+		}
+		content, err := json.Marshal(metaData)
+		if err != nil {
+			return err
+		}
+		files = append(files, isoFile{"openstack/latest/meta_data.json", content})
+		if len(options.userData) != 0 {
+			files = append(files, isoFile{"openstack/latest/user_data", options.userData})
+		}
+		if len(options.networkConfig) != 0 {
+			files = append(files, isoFile{"openstack/latest/network_data.json", options.networkConfig})
+		}
+	} else {
+		volumeID = "cidata"
+		content, err := yaml.Marshal(noCloudMetaData{InstanceID: id, LocalHostname: hostname, PublicKeys: options.sshKeys})
+		if err != nil {
+			return err
+		}
+		files = append(files, isoFile{"meta-data", content})
+		// NoCloud needs a user-data file, even an empty one
+		userData := options.userData
+		if len(userData) == 0 {
+			userData = []byte("#cloud-config\n")
+		}
+		files = append(files, isoFile{"user-data", userData})
+		if len(options.networkConfig) != 0 {
+			files = append(files, isoFile{"network-config", options.networkConfig})
+		}
+	}
+	return writeISO9660(w, volumeID, files, time.Now())
+}
+
+// Attaches the cloud-init ISO to a new VM and powers it on, then detaches the ISO once the
+// guest reports an IP address if asked to.
+func customizeVM(id string, name string, options *cloudInitOptions, w io.Writer, c *cli.Context) error {
+	isScripting := c.GlobalIsSet("non-interactive")
+	hostname := options.hostname
+	if len(hostname) == 0 {
+		hostname = name
+	}
+
+	var iso bytes.Buffer
+	err := writeCloudInitISO(&iso, options, id, hostname)
+	if err != nil {
+		return err
+	}
+	err = waitForTask(client.Esxclient.VMs.AttachISO(id, &iso, cloudInitISOName))
+	if err != nil {
+		return fmt.Errorf("Attaching the cloud-init ISO to VM %s failed: %s", name, err)
+	}
+	err = waitForTask(client.Esxclient.VMs.Start(id))
+	if err != nil {
+		return fmt.Errorf("Starting VM %s failed: %s", name, err)
+	}
+	if !isScripting {
+		fmt.Fprintf(w, "VM %s started with its cloud-init ISO\n", name)
+	}
+	if !options.detachISO {
+		return nil
+	}
+
+	ip, err := waitForVMIP(id, vmIPTimeout)
+	if err != nil {
+		return err
+	}
+	err = waitForTask(client.Esxclient.VMs.DetachISO(id))
+	if err != nil {
+		return fmt.Errorf("Detaching the cloud-init ISO from VM %s failed: %s", name, err)
+	}
+	if !isScripting {
+		fmt.Fprintf(w, "VM %s has IP address %s, cloud-init ISO detached\n", name, ip)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestCreateVMWithCloudInit(t *testing.T) {
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "fake_project_name", ID: "fake_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	task := photon.Task{ID: "fake-vm-task-ID", State: "COMPLETED", Entity: photon.Entity{ID: "fake_vm_ID"}}
+	taskResponse, err := json.Marshal(task)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=fake_project_name",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+task.ID,
+		mocks.CreateResponder(200, string(taskResponse[:])))
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/projects/fake_project_ID/vms",
+		mocks.CreateResponder(200, string(taskResponse[:])))
+
+	var calls []string
+	var iso []byte
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/vms/fake_vm_ID/attach_iso",
+		func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "attach_iso")
+			file, header, err := req.FormFile("file")
+			if err != nil || header.Filename != cloudInitISOName {
+				t.Error("Expected the cloud-init ISO to be uploaded: ", err)
+			} else {
+				iso, _ = ioutil.ReadAll(file)
+			}
+			return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+		})
+	for _, operation := range []string{"start", "detach_iso"} {
+		operation := operation
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/fake_vm_ID/"+operation,
+			func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, operation)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+	}
+
+	// The guest reports its IP address on the second poll
+	networksTask := photon.Task{ID: "fake-networks-task-ID", State: "COMPLETED"}
+	networksTaskResponse, err := json.Marshal(networksTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/fake_vm_ID/networks",
+		mocks.CreateResponder(200, string(networksTaskResponse[:])))
+	polls := 0
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+networksTask.ID,
+		func(req *http.Request) (*http.Response, error) {
+			polls++
+			network := map[string]interface{}{"network": "VM VLAN", "macAddress": "00:50:56:02:00:3f"}
+			if polls > 1 {
+				network["ipAddress"] = "10.144.121.12"
+			}
+			networksTask.ResourceProperties = map[string]interface{}{"networkConnections": []interface{}{network}}
+			response, err := json.Marshal(networksTask)
+			if err != nil {
+				t.Error("Not expecting error serializing task")
+			}
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+	vmWaitInitialInterval = time.Millisecond
+
+	userData := writeVMSpecFile(t, "user-data", "#cloud-config\npackages: [nginx]\n")
+	defer os.RemoveAll(filepath.Dir(userData))
+	sshKey := writeVMSpecFile(t, "id_rsa.pub", "ssh-rsa AAAAB3NzaC1yc2E user@host\n")
+	defer os.RemoveAll(filepath.Dir(sshKey))
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "web1", "name")
+	set.String("flavor", "core-100", "flavor")
+	set.String("image", "2f5e8a4c-6b1d-4c7e-9a3f-0d1e2c3b4a59", "image")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	set.String("user-data", userData, "user data")
+	set.Var(&cli.StringSlice{sshKey}, "ssh-key", "ssh key")
+	set.String("cloud-init-datasource", "nocloud", "datasource")
+	set.Bool("detach-iso", true, "detach iso")
+	err = set.Parse([]string{"--detach-iso"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	err = createVM(cxt)
+	if err != nil {
+		t.Error("Not expecting creating a VM with cloud-init to fail: ", err)
+	}
+	if strings.Join(calls, ",") != "attach_iso,start,detach_iso" {
+		t.Errorf("Unexpected VM operations: %v", calls)
+	}
+	if polls != 2 {
+		t.Errorf("Expected the networks to be polled until the IP is reported, got %d polls", polls)
+	}
+
+	label, files := readISOTree(t, iso, isoJolietTree)
+	if label != "cidata" {
+		t.Errorf("Unexpected volume label '%s'", label)
+	}
+	if files["user-data"] != "#cloud-config\npackages: [nginx]\n" {
+		t.Errorf("Unexpected user-data: %s", files["user-data"])
+	}
+	metaData := files["meta-data"]
+	if !strings.Contains(metaData, "instance-id: fake_vm_ID") || !strings.Contains(metaData, "local-hostname: web1") ||
+		!strings.Contains(metaData, "- ssh-rsa AAAAB3NzaC1yc2E user@host\n") {
+		t.Errorf("Unexpected meta-data: %s", metaData)
+	}
+}
@@ -68,7 +68,7 @@ func GetClusterCommand() cli.Command {
 					},
 					cli.StringFlag{
 						Name:  "network_id, w",
-						Usage: "VM network ID",
+						Usage: "VM network name or ID",
 					},
 					cli.IntFlag{
 						Name:  "slave_count, s",
@@ -290,6 +290,13 @@ func createCluster(c *cli.Context, w io.Writer) error {
 		slave_count = DEFAULT_SLAVE_COUNT
 	}
 
+	if len(network_id) != 0 {
+		network_id, err = resolveNetworkID(network_id)
+		if err != nil {
+			return err
+		}
+	}
+
 	if !utils.IsNonInteractive(c) {
 		dns, err = askForInput("Cluster DNS server: ", dns)
 		if err != nil {
@@ -497,6 +504,11 @@ func showCluster(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveClusterID(id)
+	if err != nil {
+		return err
+	}
+
 	cluster, err := client.Esxclient.Clusters.Get(id)
 	if err != nil {
 		return err
@@ -595,6 +607,11 @@ func listVms(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	cluster_id, err = resolveClusterID(cluster_id)
+	if err != nil {
+		return err
+	}
+
 	vms, err := client.Esxclient.Clusters.GetVMs(cluster_id)
 	if err != nil {
 		return err
@@ -689,6 +706,11 @@ func deleteCluster(c *cli.Context) error {
 		return err
 	}
 
+	cluster_id, err = resolveClusterID(cluster_id)
+	if err != nil {
+		return err
+	}
+
 	if !utils.IsNonInteractive(c) {
 		fmt.Printf("\nDeleting cluster %s\n", cluster_id)
 	}
@@ -11,13 +11,16 @@ package command
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/vmware/photon-controller-cli/photon/manifest"
 	"github.com/vmware/photon-controller-cli/photon/utils"
 
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
@@ -42,6 +45,7 @@ func (ip ipsSorter) Less(i, j int) bool { return ip[i].ips < ip[j].ips }
 //              show;       Usage: deployment show [<id>]
 //              list-hosts; Usage: deployment list-hosts [<id>]
 //              list-vms;   Usage: deployment list-vms [<id>]
+//              export-dcmap; Usage: deployment export-dcmap [<id> <options>]
 
 //              update-image-datastores;        Usage: deployment update-image-datastores [<id> <options>]
 
@@ -102,6 +106,22 @@ func GetDeploymentsCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "export-dcmap",
+				Usage: "Generates a DC map from the deployment, hosts and availability zones",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "file, f",
+						Usage: "File to write the DC map to, defaults to stdout",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := exportDcMap(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "enable-cluster-type",
 				Usage: "Enable cluster type for deployment",
@@ -494,6 +514,125 @@ func listDeploymentVms(c *cli.Context) error {
 	return nil
 }
 
+// Generates the DC map of a running deployment. Passwords cannot be read back
+// and are written as ${...} placeholders that are filled in from the environment.
+func exportDcMap(c *cli.Context) error {
+	id, err := getDeploymentId(c)
+	if err != nil {
+		return err
+	}
+
+	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
+	if err != nil {
+		return err
+	}
+
+	deployment, err := client.Esxclient.Deployments.Get(id)
+	if err != nil {
+		return err
+	}
+	hosts, err := client.Esxclient.Deployments.GetHosts(id)
+	if err != nil {
+		return err
+	}
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return err
+	}
+
+	dcMap, err := getDcMapFromDeployment(deployment, hosts.Items, zones.Items)
+	if err != nil {
+		return err
+	}
+	out, err := manifest.RenderInstallation(dcMap)
+	if err != nil {
+		return err
+	}
+
+	file := c.String("file")
+	if len(file) == 0 {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(file, out, 0600)
+}
+
+func getDcMapFromDeployment(deployment *photon.Deployment, hosts []photon.Host,
+	zones []photon.AvailabilityZone) (*manifest.Installation, error) {
+	dcMap := &manifest.Installation{}
+
+	dcMap.Deployment.ResumeSystem = deployment.State == "READY"
+	dcMap.Deployment.ImageDatastores = deployment.ImageDatastores
+	dcMap.Deployment.UseImageDatastoreForVms = deployment.UseImageDatastoreForVms
+	dcMap.Deployment.SyslogEndpoint = deployment.SyslogEndpoint
+	dcMap.Deployment.NTPEndpoint = deployment.NTPEndpoint
+	dcMap.Deployment.LoadBalancerEnabled = strconv.FormatBool(deployment.LoadBalancerEnabled)
+
+	if deployment.Stats != nil {
+		dcMap.Deployment.StatsEnabled = deployment.Stats.Enabled
+		dcMap.Deployment.StatsStoreEndpoint = deployment.Stats.StoreEndpoint
+		dcMap.Deployment.StatsPort = deployment.Stats.StorePort
+	}
+	if deployment.Auth != nil && deployment.Auth.Enabled {
+		dcMap.Deployment.AuthEnabled = true
+		dcMap.Deployment.AuthTenant = deployment.Auth.Tenant
+		dcMap.Deployment.AuthUsername = deployment.Auth.Username
+		dcMap.Deployment.AuthPassword = "${OAUTH_PASSWORD}"
+		dcMap.Deployment.AuthSecurityGroups = deployment.Auth.SecurityGroups
+	}
+	if deployment.NetworkConfiguration != nil && deployment.NetworkConfiguration.Enabled {
+		dcMap.Deployment.VirtualNetworkEnabled = true
+		dcMap.Deployment.NetworkManagerAddress = deployment.NetworkConfiguration.Address
+		dcMap.Deployment.NetworkManagerUsername = deployment.NetworkConfiguration.Username
+		dcMap.Deployment.NetworkManagerPassword = "${NETWORK_MANAGER_PASSWORD}"
+		dcMap.Deployment.NetworkZoneId = deployment.NetworkConfiguration.NetworkZoneId
+		dcMap.Deployment.NetworkTopRouterId = deployment.NetworkConfiguration.TopRouterId
+	}
+
+	zoneNames := make(map[string]string)
+	for _, zone := range zones {
+		zoneNames[zone.ID] = zone.Name
+	}
+
+	// Hosts that only differ by address are written as a single entry
+	type hostGroup struct {
+		host photon.Host
+		ips  []string
+	}
+	var groups []*hostGroup
+	groupIndex := make(map[string]*hostGroup)
+	for _, host := range hosts {
+		if name, present := zoneNames[host.AvailabilityZone]; present {
+			host.AvailabilityZone = name
+		}
+		var metadata []string
+		for key, value := range host.Metadata {
+			metadata = append(metadata, key+"="+value)
+		}
+		sort.Strings(metadata)
+		key := fmt.Sprintf("%s|%s|%v|%v", host.Username, host.AvailabilityZone, host.Tags, metadata)
+
+		group, present := groupIndex[key]
+		if !present {
+			group = &hostGroup{host: host}
+			groupIndex[key] = group
+			groups = append(groups, group)
+		}
+		group.ips = append(group.ips, host.Address)
+	}
+
+	for _, group := range groups {
+		ipRanges, err := compressIpRanges(group.ips)
+		if err != nil {
+			return nil, err
+		}
+		dcMap.AddHost(ipRanges, group.host.Username, "${ESX_PASSWORD}", group.host.AvailabilityZone,
+			group.host.Tags, group.host.Metadata)
+	}
+
+	return dcMap, nil
+}
+
 // Update the image datastores using the information carried in cli.Context.
 func updateImageDatastores(c *cli.Context) error {
 	id, err := getDeploymentId(c)
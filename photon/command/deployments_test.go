@@ -12,14 +12,18 @@ package command
 import (
 	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/manifest"
 	"github.com/vmware/photon-controller-cli/photon/mocks"
 
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/gopkg.in/yaml.v2"
 )
 
 type MockHostsPage struct {
@@ -583,3 +587,110 @@ func TestDisableClusterType(t *testing.T) {
 		t.Error("Not expecting pauseBackgroundTasks to fail")
 	}
 }
+
+func TestExportDcMap(t *testing.T) {
+	deployment := photon.Deployment{
+		ID:                  "1",
+		State:               "READY",
+		ImageDatastores:     []string{"ds1", "ds2"},
+		NTPEndpoint:         "ntp.local",
+		SyslogEndpoint:      "syslog.local",
+		LoadBalancerEnabled: false,
+		Auth: &photon.AuthInfo{
+			Enabled:        true,
+			Endpoint:       "lightwave.local",
+			Tenant:         "esxcloud",
+			Username:       "administrator",
+			Password:       "secret",
+			SecurityGroups: []string{"esxcloud\\admins"},
+		},
+		Stats: &photon.StatsInfo{Enabled: true, StoreEndpoint: "stats.local", StorePort: 2004},
+	}
+	deploymentResponse, err := json.Marshal(deployment)
+	if err != nil {
+		t.Error("Not expecting error serializing deployment")
+	}
+	hosts := MockHostsPage{
+		Items: []photon.Host{
+			{ID: "host-1", Username: "root", Address: "10.0.0.2", AvailabilityZone: "zone-1", Tags: []string{"CLOUD"}},
+			{ID: "host-2", Username: "root", Address: "10.0.0.1", AvailabilityZone: "zone-1", Tags: []string{"CLOUD"}},
+			{ID: "host-3", Username: "root", Address: "10.0.0.3", AvailabilityZone: "zone-1", Tags: []string{"CLOUD"}},
+			{ID: "host-4", Username: "root", Address: "10.0.0.10", Tags: []string{"MGMT"},
+				Metadata: map[string]string{"MANAGEMENT_VM_IPS": "10.0.1.1"}},
+		},
+	}
+	hostsResponse, err := json.Marshal(hosts)
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	zones := photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}},
+	}
+	zonesResponse, err := json.Marshal(zones)
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/1",
+		mocks.CreateResponder(200, string(deploymentResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/1/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	f, err := ioutil.TempFile("", "exportedDcMap")
+	if err != nil {
+		t.Error("Fail to create temporary DC map")
+	}
+	defer os.Remove(f.Name())
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("file", f.Name(), "file")
+	err = set.Parse([]string{"1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	err = exportDcMap(cxt)
+	if err != nil {
+		t.Error("Not expecting export-dcmap to fail: ", err)
+	}
+
+	// The passwords are placeholders for environment variables, so the file is read as written
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal("Not expecting error reading the exported DC map: ", err)
+	}
+	dcMap := &manifest.Installation{}
+	err = yaml.Unmarshal(content, dcMap)
+	if err != nil {
+		t.Fatal("Not expecting error parsing the exported DC map: ", err)
+	}
+	if !dcMap.Deployment.ResumeSystem || dcMap.Deployment.LoadBalancerEnabled != "false" ||
+		dcMap.Deployment.AuthPassword != "${OAUTH_PASSWORD}" || dcMap.Deployment.StatsPort != 2004 {
+		t.Errorf("Unexpected deployment in exported DC map: %+v", dcMap.Deployment)
+	}
+	if len(dcMap.Hosts) != 2 {
+		t.Fatalf("Expected 2 host entries, got %d", len(dcMap.Hosts))
+	}
+	if dcMap.Hosts[0].IpRanges != "10.0.0.1-10.0.0.3" || dcMap.Hosts[0].AvailabilityZone != "az1" ||
+		dcMap.Hosts[0].Password != "${ESX_PASSWORD}" {
+		t.Errorf("Unexpected first host entry: %+v", dcMap.Hosts[0])
+	}
+	if dcMap.Hosts[1].IpRanges != "10.0.0.10" || dcMap.Hosts[1].Metadata["MANAGEMENT_VM_IPS"] != "10.0.1.1" {
+		t.Errorf("Unexpected second host entry: %+v", dcMap.Hosts[1])
+	}
+}
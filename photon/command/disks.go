@@ -10,8 +10,10 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
@@ -98,7 +100,7 @@ func GetDiskCommand() cli.Command {
 			{
 				Name:  "list",
 				Usage: "List all disks",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "tenant, t",
 						Usage: "Tenant name",
@@ -115,7 +117,7 @@ func GetDiskCommand() cli.Command {
 						Name:  "name, n",
 						Usage: "disk name",
 					},
-				},
+				}, pagingFlags()...),
 				Action: func(c *cli.Context) {
 					err := listDisks(c)
 					if err != nil {
@@ -247,6 +249,11 @@ func deleteDisk(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveDiskID(id)
+	if err != nil {
+		return err
+	}
+
 	deleteTask, err := client.Esxclient.Disks.Delete(id)
 	if err != nil {
 		return err
@@ -274,6 +281,11 @@ func showDisk(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveDiskID(id)
+	if err != nil {
+		return err
+	}
+
 	disk, err := client.Esxclient.Disks.Get(id)
 	if err != nil {
 		return err
@@ -312,8 +324,9 @@ func listDisks(c *cli.Context) error {
 	summaryView := c.IsSet("summary")
 
 	name := c.String("name")
-	options := &photon.DiskGetOptions{
-		Name: name,
+	paging, err := getListPaging(c)
+	if err != nil {
+		return err
 	}
 
 	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
@@ -330,28 +343,36 @@ func listDisks(c *cli.Context) error {
 		return err
 	}
 
-	diskList, err := client.Esxclient.Projects.GetDisks(project.ID, options)
-	if err != nil {
-		return err
+	query := url.Values{}
+	if len(name) != 0 {
+		query.Set("name", name)
 	}
 
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+	count := 0
 	stateCount := make(map[string]int)
-	for _, disk := range diskList.Items {
-		stateCount[disk.State]++
-	}
-
-	if c.GlobalIsSet("non-interactive") {
-		if !summaryView {
-			for _, disk := range diskList.Items {
-				fmt.Printf("%s\t%s\t%s\n", disk.ID, disk.Name, disk.State)
+	nextPageToken, err := streamList("/projects/"+project.ID+"/disks", query, paging, func(items []json.RawMessage) error {
+		disks := make([]photon.PersistentDisk, len(items))
+		for i, item := range items {
+			err := json.Unmarshal(item, &disks[i])
+			if err != nil {
+				return err
 			}
+			stateCount[disks[i].State]++
 		}
-	} else {
-		if !summaryView {
-			w := new(tabwriter.Writer)
-			w.Init(os.Stdout, 4, 4, 2, ' ', 0)
-			fmt.Fprintf(w, "ID\tName\tState\n")
-			for _, disk := range diskList.Items {
+
+		if c.GlobalIsSet("non-interactive") {
+			if !summaryView {
+				for _, disk := range disks {
+					fmt.Printf("%s\t%s\t%s\n", disk.ID, disk.Name, disk.State)
+				}
+			}
+		} else if !summaryView {
+			if count == 0 {
+				fmt.Fprintf(w, "ID\tName\tState\n")
+			}
+			for _, disk := range disks {
 				fmt.Fprintf(w, "%s\t%s\t%s\n", disk.ID, disk.Name, disk.State)
 			}
 			err := w.Flush()
@@ -359,11 +380,20 @@ func listDisks(c *cli.Context) error {
 				return err
 			}
 		}
-		fmt.Printf("\nTotal: %d\n", len(diskList.Items))
+		count += len(disks)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Printf("\nTotal: %d\n", count)
 		for key, value := range stateCount {
 			fmt.Printf("%s: %d\n", key, value)
 		}
 	}
+	printNextPageToken(nextPageToken, os.Stdout, c)
 
 	return nil
 }
@@ -382,6 +412,11 @@ func getDiskTasks(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveDiskID(id)
+	if err != nil {
+		return err
+	}
+
 	options := &photon.TaskGetOptions{
 		State: state,
 	}
@@ -213,6 +213,11 @@ func deleteFlavor(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveFlavorID(id, "")
+	if err != nil {
+		return err
+	}
+
 	deleteTask, err := client.Esxclient.Flavors.Delete(id)
 	if err != nil {
 		return err
@@ -286,6 +291,11 @@ func showFlavor(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveFlavorID(id, "")
+	if err != nil {
+		return err
+	}
+
 	flavor, err := client.Esxclient.Flavors.Get(id)
 	if err != nil {
 		return err
@@ -329,6 +339,11 @@ func getFlavorTasks(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveFlavorID(id, "")
+	if err != nil {
+		return err
+	}
+
 	taskList, err := client.Esxclient.Flavors.GetTasks(id, options)
 	if err != nil {
 		return err
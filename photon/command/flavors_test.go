@@ -136,6 +136,7 @@ func TestCreateDeleteFlavor(t *testing.T) {
 		"GET",
 		server.URL+"/tasks/"+queuedTask.ID,
 		mocks.CreateResponder(200, string(taskresponse[:])))
+	registerResolveList(server.URL+"/flavors", queuedTask.Entity.ID)
 
 	set = flag.NewFlagSet("test", 0)
 	err = set.Parse([]string{queuedTask.Entity.ID})
@@ -170,6 +171,7 @@ func TestShowFlavor(t *testing.T) {
 		mocks.CreateResponder(200, string(response[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/flavors", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -229,6 +231,7 @@ func TestFlavorTasks(t *testing.T) {
 
 	defer server.Close()
 
+	registerResolveList(server.URL+"/flavors", "fake-id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -0,0 +1,276 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+const (
+	drainPolicyStop = "stop"
+	drainPolicyFail = "fail"
+)
+
+// Interval between two checks for running VMs on a host being drained, changed by tests
+var drainPollInterval = 5 * time.Second
+
+// What happened to a VM while its host was drained
+type drainResult struct {
+	VMID   string `json:"vmId"`
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Suspends a host, stops or reports the VMs still running on it and puts it into maintenance mode
+func drainHostCommand(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "host drain <id> [<options>]")
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	policy := c.String("policy")
+	if policy != drainPolicyStop && policy != drainPolicyFail {
+		return fmt.Errorf("Unknown policy '%s', use %s or %s", policy, drainPolicyStop, drainPolicyFail)
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
+	results, drainErr := drainHost(id, policy, c.Duration("timeout"), utils.IsNonInteractive(c))
+	err = printDrainResults(results, w, c)
+	if err != nil {
+		return err
+	}
+	if drainErr != nil {
+		return drainErr
+	}
+	if !utils.NeedsFormatting(c) && !c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "Host %s is in maintenance mode\n", id)
+	}
+	return nil
+}
+
+// Takes a drained host out of maintenance mode and resumes it
+func undrainHostCommand(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "host undrain <id>")
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
+	err = undrainHost(id)
+	if err != nil {
+		return err
+	}
+	if !utils.NeedsFormatting(c) && !c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "Host %s is ready\n", id)
+	}
+	return nil
+}
+
+// Drains a host. With the stop policy running VMs are stopped, with the fail policy they are
+// reported and the host is left suspended. Returns what happened to every VM on the host.
+func drainHost(id string, policy string, timeout time.Duration, isScripting bool) ([]drainResult, error) {
+	host, err := client.Esxclient.Hosts.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if host.State == "MAINTENANCE" {
+		return nil, nil
+	}
+
+	vms, err := client.Esxclient.Hosts.GetVMs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if host.State != "SUSPENDED" {
+		if !isScripting {
+			fmt.Printf("Suspending host %s\n", id)
+		}
+		err = waitForTask(client.Esxclient.Hosts.Suspend(id))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var results []drainResult
+	running := 0
+	failed := 0
+	for _, vm := range vms.Items {
+		result := drainResult{VMID: vm.ID, Name: vm.Name, State: vm.State, Action: "-"}
+		if isVMRunning(vm.State) {
+			if policy == drainPolicyStop {
+				if !isScripting {
+					fmt.Printf("Stopping VM %s (%s)\n", vm.Name, vm.ID)
+				}
+				result.Action, err = stopDrainedVM(vm)
+				if err != nil {
+					result.Error = err.Error()
+					failed++
+				}
+			} else {
+				result.Action = "still running"
+			}
+			running++
+		}
+		results = append(results, result)
+	}
+
+	if running != 0 && policy == drainPolicyFail {
+		return results, fmt.Errorf("%d VMs are running on host %s, stop or delete them or use --policy %s",
+			running, id, drainPolicyStop)
+	}
+	// The host would never become idle, no need to wait for the timeout
+	if failed != 0 {
+		return results, fmt.Errorf("%d VMs could not be stopped on host %s, it is left suspended", failed, id)
+	}
+
+	err = waitForHostIdle(id, timeout)
+	if err != nil {
+		return results, err
+	}
+
+	if !isScripting {
+		fmt.Printf("Entering maintenance mode on host %s\n", id)
+	}
+	err = waitForTask(client.Esxclient.Hosts.EnterMaintenanceMode(id))
+	return results, err
+}
+
+// Takes a host out of maintenance mode and resumes it if it stays suspended
+func undrainHost(id string) error {
+	host, err := client.Esxclient.Hosts.Get(id)
+	if err != nil {
+		return err
+	}
+	if host.State == "MAINTENANCE" {
+		err = waitForTask(client.Esxclient.Hosts.ExitMaintenanceMode(id))
+		if err != nil {
+			return err
+		}
+		host, err = client.Esxclient.Hosts.Get(id)
+		if err != nil {
+			return err
+		}
+	}
+	if host.State == "SUSPENDED" {
+		return waitForTask(client.Esxclient.Hosts.Resume(id))
+	}
+	return nil
+}
+
+// Polls the VMs of a host until none of them is running
+func waitForHostIdle(id string, timeout time.Duration) error {
+	start := time.Now()
+	for {
+		vms, err := client.Esxclient.Hosts.GetVMs(id)
+		if err != nil {
+			return err
+		}
+		running := 0
+		for _, vm := range vms.Items {
+			if isVMRunning(vm.State) {
+				running++
+			}
+		}
+		if running == 0 {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return fmt.Errorf("Timed out waiting for %d VMs to stop on host %s", running, id)
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// Stops a VM of a drained host. A suspended VM cannot be stopped, so it is resumed first.
+// Returns the action taken for the drain results.
+func stopDrainedVM(vm photon.VM) (string, error) {
+	action := "stopped"
+	if vm.State == "SUSPENDED" {
+		err := waitForTask(client.Esxclient.VMs.Resume(vm.ID))
+		if err != nil {
+			return "resume failed", err
+		}
+		action = "resumed and stopped"
+	}
+	err := waitForTask(client.Esxclient.VMs.Stop(vm.ID))
+	if err != nil {
+		return "stop failed", err
+	}
+	return action, nil
+}
+
+// Suspended VMs still hold the resources of the host
+func isVMRunning(state string) bool {
+	return state == "STARTED" || state == "SUSPENDED"
+}
+
+// Waits on the task returned by an API call
+func waitForTask(task *photon.Task, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = client.Esxclient.Tasks.Wait(task.ID)
+	return err
+}
+
+func printDrainResults(results []drainResult, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.VMID, result.Name, result.State, result.Action, result.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(results, w, c)
+	} else {
+		if len(results) == 0 {
+			return nil
+		}
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "VM ID\tName\tState\tAction\tError\n")
+		for _, result := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.VMID, result.Name, result.State, result.Action,
+				valueOrDash(result.Error))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(results))
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func registerHostDrainResponders(t *testing.T, serverURL string, hostState string, vms []photon.VM) {
+	host := photon.Host{ID: "host-1", Address: "10.0.0.1", State: hostState}
+	hostResponse, err := json.Marshal(host)
+	if err != nil {
+		t.Error("Not expecting error serializing host")
+	}
+	vmsResponse, err := json.Marshal(photon.VMs{Items: vms})
+	if err != nil {
+		t.Error("Not expecting error serializing vms")
+	}
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/hosts/host-1",
+		mocks.CreateResponder(200, string(hostResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/hosts/host-1/vms",
+		mocks.CreateResponder(200, string(vmsResponse[:])))
+
+	for _, operation := range []string{"suspend", "resume", "enter_maintenance", "exit_maintenance"} {
+		task := photon.Task{ID: operation + "-task", State: "COMPLETED", Entity: photon.Entity{ID: "host-1"}}
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			serverURL+"/hosts/host-1/"+operation,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+		mocks.RegisterResponder(
+			"GET",
+			serverURL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+}
+
+func TestDrainHost(t *testing.T) {
+	originalPollInterval := drainPollInterval
+	drainPollInterval = time.Millisecond
+	defer func() {
+		drainPollInterval = originalPollInterval
+	}()
+
+	vms := []photon.VM{
+		{ID: "vm-1", Name: "web", State: "STARTED"},
+		{ID: "vm-2", Name: "db", State: "STOPPED"},
+	}
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerHostDrainResponders(t, server.URL, "READY", vms)
+
+	// Once the VM is stopped the host has no running VMs left
+	stopTask := photon.Task{ID: "stop-task", State: "COMPLETED", Entity: photon.Entity{ID: "vm-1"}}
+	stopResponse, err := json.Marshal(stopTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	stoppedVMs := []photon.VM{
+		{ID: "vm-1", Name: "web", State: "STOPPED"},
+		{ID: "vm-2", Name: "db", State: "STOPPED"},
+	}
+	stoppedResponse, err := json.Marshal(photon.VMs{Items: stoppedVMs})
+	if err != nil {
+		t.Error("Not expecting error serializing vms")
+	}
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/vms/vm-1/stop",
+		func(req *http.Request) (*http.Response, error) {
+			mocks.RegisterResponder(
+				"GET",
+				server.URL+"/hosts/host-1/vms",
+				mocks.CreateResponder(200, string(stoppedResponse[:])))
+			return mocks.CreateResponder(200, string(stopResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/stop-task",
+		mocks.CreateResponder(200, string(stopResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	results, err := drainHost("host-1", drainPolicyStop, time.Minute, true)
+	if err != nil {
+		t.Error("Not expecting drain to fail: ", err)
+	}
+	if len(results) != 2 || results[0].Action != "stopped" || results[1].Action != "-" {
+		t.Errorf("Unexpected drain results: %+v", results)
+	}
+}
+
+func TestDrainHostSuspendedVM(t *testing.T) {
+	vms := []photon.VM{
+		{ID: "vm-3", Name: "cache", State: "SUSPENDED"},
+		{ID: "vm-4", Name: "queue", State: "STARTED"},
+	}
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerHostDrainResponders(t, server.URL, "READY", vms)
+
+	var calls []string
+	register := func(vmID string, operation string, state string) {
+		task := photon.Task{ID: operation + "-" + vmID + "-task", State: state, Entity: photon.Entity{ID: vmID}}
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/"+vmID+"/"+operation,
+			func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, operation+" "+vmID)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+	register("vm-3", "resume", "COMPLETED")
+	register("vm-3", "stop", "COMPLETED")
+	register("vm-4", "stop", "ERROR")
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	// The failed stop is reported right away instead of after the timeout
+	results, err := drainHost("host-1", drainPolicyStop, time.Hour, true)
+	if err == nil || !strings.Contains(err.Error(), "1 VMs could not be stopped") {
+		t.Errorf("Expected drain to fail on the VM that could not be stopped, got %v", err)
+	}
+	if strings.Join(calls, ",") != "resume vm-3,stop vm-3,stop vm-4" {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+	if len(results) != 2 || results[0].Action != "resumed and stopped" || results[1].Action != "stop failed" {
+		t.Errorf("Unexpected drain results: %+v", results)
+	}
+}
+
+func TestDrainHostFailPolicy(t *testing.T) {
+	vms := []photon.VM{{ID: "vm-1", Name: "web", State: "STARTED"}}
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerHostDrainResponders(t, server.URL, "READY", vms)
+
+	registerResolveList(server.URL+"/hosts", "host-1")
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("policy", drainPolicyFail, "policy")
+	set.Duration("timeout", time.Minute, "timeout")
+	err = set.Parse([]string{"host-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = drainHostCommand(cxt, &buf)
+	if err == nil {
+		t.Error("Expected drain to fail with running VMs")
+	}
+	if !strings.Contains(buf.String(), "vm-1\tweb\tSTARTED\tstill running") {
+		t.Errorf("Expected the running VM to be reported, got: %s", buf.String())
+	}
+}
+
+func TestUndrainHost(t *testing.T) {
+	server := mocks.NewTestServer()
+	defer server.Close()
+	registerHostDrainResponders(t, server.URL, "MAINTENANCE", nil)
+
+	registerResolveList(server.URL+"/hosts", "host-1")
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	set := flag.NewFlagSet("test", 0)
+	err := set.Parse([]string{"host-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	var buf bytes.Buffer
+	err = undrainHostCommand(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting undrain to fail: ", err)
+	}
+}
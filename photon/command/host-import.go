@@ -0,0 +1,316 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// Prefix of the CSV columns that are added to the host metadata
+const metadataColumnPrefix = "metadata."
+
+// A host in an inventory file
+type inventoryHost struct {
+	Address          string            `json:"address"`
+	Username         string            `json:"username"`
+	Password         string            `json:"password"`
+	PasswordRef      string            `json:"password_ref"`
+	Tags             []string          `json:"tags"`
+	AvailabilityZone string            `json:"availability_zone"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// Validation error of a row of an inventory file
+type inventoryRowError struct {
+	Row     int    `json:"row"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// Creates the hosts listed in a CSV or JSON inventory file. Every row is validated before
+// any host is created.
+func importHosts(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 0, "host import --file <file> [<options>]")
+	if err != nil {
+		return err
+	}
+	file := c.String("file")
+	if len(file) == 0 {
+		return errors.New("Please provide the inventory file with --file")
+	}
+	format := c.String("format")
+	if len(format) == 0 {
+		format = "csv"
+		if strings.ToLower(filepath.Ext(file)) == ".json" {
+			format = "json"
+		}
+	}
+
+	inventory, err := loadInventory(file, format)
+	if err != nil {
+		return err
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+
+	deploymentID, err := findDeploymentID(c.String("deployment_id"))
+	if err != nil {
+		return err
+	}
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return err
+	}
+	hosts, err := client.Esxclient.Deployments.GetHosts(deploymentID)
+	if err != nil {
+		return err
+	}
+
+	hostSpecs, rowErrors := getInventoryHostSpecs(inventory, zones.Items, hosts.Items)
+	if len(rowErrors) != 0 {
+		err = printInventoryRowErrors(rowErrors, w, c)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%d of %d rows are invalid, no host was created", len(rowErrors), len(inventory))
+	}
+
+	_, err = registerHosts(hostSpecs, deploymentID, nil, c, w)
+	return err
+}
+
+func loadInventory(file string, format string) ([]inventoryHost, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		var inventory []inventoryHost
+		err = json.Unmarshal(buf, &inventory)
+		if err != nil {
+			return nil, err
+		}
+		return inventory, nil
+	case "csv":
+		return parseCSVInventory(strings.NewReader(string(buf)))
+	default:
+		return nil, fmt.Errorf("Unknown inventory format '%s', use csv or json", format)
+	}
+}
+
+// Parses a CSV inventory. The first line names the columns: address, username, password,
+// password_ref, tags, availability_zone and metadata.<key> columns for the host metadata.
+func parseCSVInventory(r io.Reader) ([]inventoryHost, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Inventory file is empty")
+	}
+
+	header := records[0]
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if !strings.HasPrefix(column, metadataColumnPrefix) {
+			column = strings.ToLower(column)
+		}
+		switch column {
+		case "address", "username", "password", "password_ref", "tags", "availability_zone":
+		default:
+			if !strings.HasPrefix(column, metadataColumnPrefix) || len(column) == len(metadataColumnPrefix) {
+				return nil, fmt.Errorf("Unknown inventory column '%s'", header[i])
+			}
+		}
+		header[i] = column
+	}
+
+	var inventory []inventoryHost
+	for _, record := range records[1:] {
+		host := inventoryHost{Metadata: map[string]string{}}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "address":
+				host.Address = value
+			case "username":
+				host.Username = value
+			case "password":
+				host.Password = value
+			case "password_ref":
+				host.PasswordRef = value
+			case "tags":
+				if len(value) != 0 {
+					host.Tags = regexp.MustCompile(`\s*[,;|]\s*`).Split(value, -1)
+				}
+			case "availability_zone":
+				host.AvailabilityZone = value
+			default:
+				if len(value) != 0 {
+					host.Metadata[strings.TrimPrefix(header[i], metadataColumnPrefix)] = value
+				}
+			}
+		}
+		inventory = append(inventory, host)
+	}
+	return inventory, nil
+}
+
+// Validates the inventory and builds the host create specs. Rows are numbered from 1.
+func getInventoryHostSpecs(inventory []inventoryHost, zones []photon.AvailabilityZone,
+	existingHosts []photon.Host) ([]photon.HostCreateSpec, []inventoryRowError) {
+	zoneIDs := make(map[string]string)
+	for _, zone := range zones {
+		zoneIDs[zone.Name] = zone.ID
+	}
+	for _, zone := range zones {
+		zoneIDs[zone.ID] = zone.ID
+	}
+	registered := make(map[string]bool)
+	for _, host := range existingHosts {
+		registered[host.Address] = true
+	}
+
+	var hostSpecs []photon.HostCreateSpec
+	var rowErrors []inventoryRowError
+	seen := make(map[string]int)
+	for i, host := range inventory {
+		row := i + 1
+		fail := func(format string, args ...interface{}) {
+			rowErrors = append(rowErrors, inventoryRowError{row, host.Address, fmt.Sprintf(format, args...)})
+		}
+
+		if net.ParseIP(host.Address) == nil {
+			fail("'%s' is not a valid IP address", host.Address)
+			continue
+		}
+		if previous, present := seen[host.Address]; present {
+			fail("address is already listed in row %d", previous)
+			continue
+		}
+		seen[host.Address] = row
+		if registered[host.Address] {
+			fail("host is already registered in the deployment")
+			continue
+		}
+		if len(host.Username) == 0 {
+			fail("username is missing")
+			continue
+		}
+		password, err := resolvePassword(host.Password, host.PasswordRef)
+		if err != nil {
+			fail("%s", err)
+			continue
+		}
+		zoneID := ""
+		if len(host.AvailabilityZone) != 0 {
+			id, present := zoneIDs[host.AvailabilityZone]
+			if !present {
+				fail("availability zone '%s' does not exist", host.AvailabilityZone)
+				continue
+			}
+			zoneID = id
+		}
+
+		metadata := host.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		hostSpecs = append(hostSpecs, photon.HostCreateSpec{
+			Username:         host.Username,
+			Password:         password,
+			Address:          host.Address,
+			AvailabilityZone: zoneID,
+			Tags:             host.Tags,
+			Metadata:         metadata,
+		})
+	}
+	return hostSpecs, rowErrors
+}
+
+// Returns the password of an inventory row. A password reference is either env:NAME or ${NAME}
+// for an environment variable, or file:PATH for the content of a file.
+func resolvePassword(password string, passwordRef string) (string, error) {
+	if len(passwordRef) == 0 {
+		if len(password) == 0 {
+			return "", errors.New("password is missing")
+		}
+		return password, nil
+	}
+	if len(password) != 0 {
+		return "", errors.New("password and password_ref cannot both be set")
+	}
+
+	name := ""
+	if strings.HasPrefix(passwordRef, "env:") {
+		name = strings.TrimPrefix(passwordRef, "env:")
+	} else if match := regexp.MustCompile(`^\$\{(\w+)\}$`).FindStringSubmatch(passwordRef); match != nil {
+		name = match[1]
+	} else if strings.HasPrefix(passwordRef, "file:") {
+		buf, err := ioutil.ReadFile(strings.TrimPrefix(passwordRef, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file: %s", err)
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	} else {
+		return "", fmt.Errorf("password_ref '%s' must be env:NAME, ${NAME} or file:PATH", passwordRef)
+	}
+
+	value, present := os.LookupEnv(name)
+	if !present {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func printInventoryRowErrors(rowErrors []inventoryRowError, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, rowError := range rowErrors {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", rowError.Row, rowError.Address, rowError.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(rowErrors, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Row\tIP\tError\n")
+		for _, rowError := range rowErrors {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", rowError.Row, valueOrDash(rowError.Address), rowError.Error)
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestParseCSVInventory(t *testing.T) {
+	csvInventory := `address,username,password_ref,tags,availability_zone,metadata.ALLOWED_DATASTORES
+10.0.0.1,root,env:ESX_PASSWORD,"CLOUD,MGMT",az1,ds1
+10.0.0.2, root, ${ESX_PASSWORD}, CLOUD, ,
+`
+	inventory, err := parseCSVInventory(strings.NewReader(csvInventory))
+	if err != nil {
+		t.Fatal("Not expecting error parsing CSV inventory: ", err)
+	}
+	if len(inventory) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(inventory))
+	}
+	if inventory[0].Address != "10.0.0.1" || inventory[0].PasswordRef != "env:ESX_PASSWORD" ||
+		len(inventory[0].Tags) != 2 || inventory[0].AvailabilityZone != "az1" ||
+		inventory[0].Metadata["ALLOWED_DATASTORES"] != "ds1" {
+		t.Errorf("Unexpected first host: %+v", inventory[0])
+	}
+	if inventory[1].Username != "root" || inventory[1].AvailabilityZone != "" || len(inventory[1].Metadata) != 0 {
+		t.Errorf("Unexpected second host: %+v", inventory[1])
+	}
+
+	_, err = parseCSVInventory(strings.NewReader("address,rack\n10.0.0.1,r1\n"))
+	if err == nil {
+		t.Error("Expected error for unknown inventory column")
+	}
+}
+
+func TestGetInventoryHostSpecs(t *testing.T) {
+	err := os.Setenv("TEST_IMPORT_PASSWORD", "secret")
+	if err != nil {
+		t.Error("Not expecting error setting environment variable")
+	}
+	defer os.Unsetenv("TEST_IMPORT_PASSWORD")
+
+	inventory := []inventoryHost{
+		{Address: "10.0.0.1", Username: "root", PasswordRef: "${TEST_IMPORT_PASSWORD}", AvailabilityZone: "az1"},
+		{Address: "10.0.0.2", Username: "root", Password: "p", AvailabilityZone: "zone-1"},
+		{Address: "10.0.0.1", Username: "root", Password: "p"},
+		{Address: "10.0.0.3", Username: "root", Password: "p"},
+		{Address: "not-an-ip", Username: "root", Password: "p"},
+		{Address: "10.0.0.4", Username: "root", PasswordRef: "env:TEST_IMPORT_MISSING"},
+		{Address: "10.0.0.5", Username: "root", Password: "p", AvailabilityZone: "az2"},
+		{Address: "10.0.0.6", Password: "p"},
+	}
+	zones := []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}}
+	existing := []photon.Host{{ID: "host-3", Address: "10.0.0.3"}}
+
+	specs, rowErrors := getInventoryHostSpecs(inventory, zones, existing)
+	if len(specs) != 2 || specs[0].Password != "secret" || specs[0].AvailabilityZone != "zone-1" ||
+		specs[1].AvailabilityZone != "zone-1" {
+		t.Errorf("Unexpected host specs: %+v", specs)
+	}
+	expectedRows := []int{3, 4, 5, 6, 7, 8}
+	if len(rowErrors) != len(expectedRows) {
+		t.Fatalf("Expected %d row errors, got %v", len(expectedRows), rowErrors)
+	}
+	for i, rowError := range rowErrors {
+		if rowError.Row != expectedRows[i] {
+			t.Errorf("Expected error for row %d, got %+v", expectedRows[i], rowError)
+		}
+	}
+}
+
+func TestImportHosts(t *testing.T) {
+	f, err := ioutil.TempFile("", "hosts.csv")
+	if err != nil {
+		t.Error("Fail to create temporary inventory file")
+	}
+	defer os.Remove(f.Name())
+
+	deployments := photon.Deployments{
+		Items: []photon.Deployment{{ID: "deployment-ID"}},
+	}
+	deploymentsResponse, err := json.Marshal(deployments)
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	zones := photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}},
+	}
+	zonesResponse, err := json.Marshal(zones)
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	hostsResponse, err := json.Marshal(MockHostsPage{Items: []photon.Host{}})
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	taskID, queued, completed, err := createTaskResponses("CREATE_HOST", "host-1")
+	if err != nil {
+		t.Error("Not expecting error serializing create host task")
+	}
+	host := photon.Host{ID: "host-1", Address: "10.0.0.1", State: "READY"}
+	hostResponse, err := json.Marshal(host)
+	if err != nil {
+		t.Error("Not expecting error serializing host")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, queued))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+taskID,
+		mocks.CreateResponder(200, completed))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/hosts/host-1",
+		mocks.CreateResponder(200, string(hostResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("file", f.Name(), "file")
+	set.Int("parallel", 10, "parallel")
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	// An invalid row stops the import before any host is created
+	err = ioutil.WriteFile(f.Name(), []byte("address,username,password,availability_zone\n"+
+		"10.0.0.1,root,p,az1\n10.0.0.2,root,p,az2\n"), 0644)
+	if err != nil {
+		t.Error("Failed to write inventory file")
+	}
+	var buf bytes.Buffer
+	err = importHosts(cxt, &buf)
+	if err == nil || !strings.Contains(buf.String(), "2\t10.0.0.2\tavailability zone 'az2' does not exist") {
+		t.Errorf("Expected import to fail on row 2, got '%v': %s", err, buf.String())
+	}
+
+	err = ioutil.WriteFile(f.Name(), []byte("address,username,password,availability_zone\n10.0.0.1,root,p,az1\n"), 0644)
+	if err != nil {
+		t.Error("Failed to write inventory file")
+	}
+	buf.Reset()
+	err = importHosts(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting import to fail: ", err)
+	}
+	if !strings.HasPrefix(buf.String(), "10.0.0.1\thost-1\t") {
+		t.Errorf("Expected the created host to be printed, got %q", buf.String())
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// Number of hosts whose VMs are fetched at the same time
+const hostReportParallelism = 10
+
+// VMs and the CPU and memory of their flavors
+type vmLoad struct {
+	VMs       int     `json:"vms"`
+	Started   int     `json:"started"`
+	Stopped   int     `json:"stopped"`
+	Suspended int     `json:"suspended"`
+	Error     int     `json:"error"`
+	CPU       float64 `json:"cpu"`
+	MemoryGB  float64 `json:"memoryGB"`
+}
+
+func (l *vmLoad) add(other vmLoad) {
+	l.VMs += other.VMs
+	l.Started += other.Started
+	l.Stopped += other.Stopped
+	l.Suspended += other.Suspended
+	l.Error += other.Error
+	l.CPU += other.CPU
+	l.MemoryGB += other.MemoryGB
+}
+
+type hostLoad struct {
+	HostID           string   `json:"hostId"`
+	Address          string   `json:"address"`
+	AvailabilityZone string   `json:"availabilityZone"`
+	EsxVersion       string   `json:"esxVersion"`
+	Tags             []string `json:"tags"`
+	vmLoad
+}
+
+type zoneLoad struct {
+	AvailabilityZone string `json:"availabilityZone"`
+	Hosts            int    `json:"hosts"`
+	vmLoad
+}
+
+// Shows the VMs and the CPU and memory they use per host, and totals per availability zone
+func hostReport(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 0, "host report [<options>]")
+	if err != nil {
+		return err
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+
+	hosts, err := client.Esxclient.Hosts.GetAll()
+	if err != nil {
+		return err
+	}
+	flavors, err := client.Esxclient.Flavors.GetAll(&photon.FlavorGetOptions{Kind: "vm"})
+	if err != nil {
+		return err
+	}
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return err
+	}
+
+	hostVMs, err := getHostVMs(hosts.Items)
+	if err != nil {
+		return err
+	}
+
+	hostLoads, zoneLoads := getHostLoads(hosts.Items, hostVMs, flavors.Items, zones.Items)
+
+	if c.Bool("by-zone") {
+		return printZoneLoads(zoneLoads, w, c)
+	}
+	err = printHostLoads(hostLoads, w, c)
+	if err != nil {
+		return err
+	}
+	if !utils.NeedsFormatting(c) && !c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "\nAvailability zones:\n")
+		err = printZoneLoads(zoneLoads, w, c)
+	}
+	return err
+}
+
+// Gets the VMs of every host, fetching several hosts at the same time
+func getHostVMs(hosts []photon.Host) ([][]photon.VM, error) {
+	hostVMs := make([][]photon.VM, len(hosts))
+	errs := runInParallel(len(hosts), hostReportParallelism, "GET_VMS", true, func(i int) error {
+		vms, err := client.Esxclient.Hosts.GetVMs(hosts[i].ID)
+		if err != nil {
+			return err
+		}
+		hostVMs[i] = vms.Items
+		return nil
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the VMs of host %s: %s", hosts[i].Address, err)
+		}
+	}
+	return hostVMs, nil
+}
+
+// Computes the load of every host and rolls it up per availability zone
+func getHostLoads(hosts []photon.Host, hostVMs [][]photon.VM, flavors []photon.Flavor,
+	zones []photon.AvailabilityZone) ([]hostLoad, []zoneLoad) {
+	flavorCosts := make(map[string][]photon.QuotaLineItem)
+	for _, flavor := range flavors {
+		flavorCosts[flavor.Name] = flavor.Cost
+	}
+	zoneNames := make(map[string]string)
+	for _, zone := range zones {
+		zoneNames[zone.ID] = zone.Name
+	}
+
+	var hostLoads []hostLoad
+	zoneLoads := make(map[string]*zoneLoad)
+	for i, host := range hosts {
+		zone := host.AvailabilityZone
+		if name, present := zoneNames[zone]; present {
+			zone = name
+		}
+		load := hostLoad{
+			HostID:           host.ID,
+			Address:          host.Address,
+			AvailabilityZone: zone,
+			EsxVersion:       host.EsxVersion,
+			Tags:             host.Tags,
+		}
+		for _, vm := range hostVMs[i] {
+			load.VMs++
+			switch vm.State {
+			case "STARTED":
+				load.Started++
+			case "STOPPED":
+				load.Stopped++
+			case "SUSPENDED":
+				load.Suspended++
+			case "ERROR":
+				load.Error++
+			}
+			for _, cost := range flavorCosts[vm.Flavor] {
+				switch cost.Key {
+				case "vm.cpu":
+					load.CPU += cost.Value
+				case "vm.memory":
+					load.MemoryGB += toGB(cost.Value, cost.Unit)
+				}
+			}
+		}
+		hostLoads = append(hostLoads, load)
+
+		zoneTotal, present := zoneLoads[zone]
+		if !present {
+			zoneTotal = &zoneLoad{AvailabilityZone: zone}
+			zoneLoads[zone] = zoneTotal
+		}
+		zoneTotal.Hosts++
+		zoneTotal.add(load.vmLoad)
+	}
+
+	var zoneNameList []string
+	for zone := range zoneLoads {
+		zoneNameList = append(zoneNameList, zone)
+	}
+	sort.Strings(zoneNameList)
+	var zoneLoadList []zoneLoad
+	for _, zone := range zoneNameList {
+		zoneLoadList = append(zoneLoadList, *zoneLoads[zone])
+	}
+	return hostLoads, zoneLoadList
+}
+
+// Converts a memory cost to GB
+func toGB(value float64, unit string) float64 {
+	switch strings.ToUpper(unit) {
+	case "B":
+		return value / (1024 * 1024 * 1024)
+	case "KB":
+		return value / (1024 * 1024)
+	case "MB":
+		return value / 1024
+	default:
+		return value
+	}
+}
+
+func printHostLoads(loads []hostLoad, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, load := range loads {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", load.HostID, load.Address, load.AvailabilityZone,
+				load.EsxVersion, strings.Join(load.Tags, ","), formatVMLoad(load.vmLoad))
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(loads, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Host ID\tIP\tAvailability Zone\tESX Version\tTags\t%s\n", vmLoadHeader)
+		for _, load := range loads {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", load.HostID, load.Address, valueOrDash(load.AvailabilityZone),
+				valueOrDash(load.EsxVersion), valueOrDash(strings.Join(load.Tags, ",")), formatVMLoad(load.vmLoad))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(loads))
+	}
+	return nil
+}
+
+func printZoneLoads(loads []zoneLoad, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, load := range loads {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", load.AvailabilityZone, load.Hosts, formatVMLoad(load.vmLoad))
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(loads, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Availability Zone\tHosts\t%s\n", vmLoadHeader)
+		for _, load := range loads {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", valueOrDash(load.AvailabilityZone), load.Hosts, formatVMLoad(load.vmLoad))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(loads))
+	}
+	return nil
+}
+
+const vmLoadHeader = "VMs\tStarted\tStopped\tSuspended\tError\tCPU\tMemory (GB)"
+
+func formatVMLoad(load vmLoad) string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t%d\t%g\t%g", load.VMs, load.Started, load.Stopped, load.Suspended,
+		load.Error, load.CPU, load.MemoryGB)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestHostReport(t *testing.T) {
+	hosts := photon.Hosts{
+		Items: []photon.Host{
+			{ID: "host-1", Address: "10.0.0.1", AvailabilityZone: "zone-1", EsxVersion: "6.0.0",
+				Tags: []string{"CLOUD", "MGMT"}},
+			{ID: "host-2", Address: "10.0.0.2", AvailabilityZone: "zone-1", EsxVersion: "6.0.0",
+				Tags: []string{"CLOUD"}},
+			{ID: "host-3", Address: "10.0.0.3", EsxVersion: "5.5.0", Tags: []string{"CLOUD"}},
+		},
+	}
+	hostsResponse, err := json.Marshal(hosts)
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	flavors := photon.FlavorList{
+		Items: []photon.Flavor{
+			{Name: "small", Kind: "vm", Cost: []photon.QuotaLineItem{
+				{Key: "vm.cpu", Value: 1, Unit: "COUNT"}, {Key: "vm.memory", Value: 512, Unit: "MB"}}},
+			{Name: "large", Kind: "vm", Cost: []photon.QuotaLineItem{
+				{Key: "vm.cpu", Value: 4, Unit: "COUNT"}, {Key: "vm.memory", Value: 8, Unit: "GB"}}},
+		},
+	}
+	flavorsResponse, err := json.Marshal(flavors)
+	if err != nil {
+		t.Error("Not expecting error serializing flavors")
+	}
+	zonesResponse, err := json.Marshal(photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	hostVMs := map[string][]photon.VM{
+		"host-1": {
+			{ID: "vm-1", Flavor: "small", State: "STARTED"},
+			{ID: "vm-2", Flavor: "large", State: "STOPPED"},
+		},
+		"host-2": {
+			{ID: "vm-3", Flavor: "small", State: "STARTED"},
+		},
+		"host-3": {},
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/flavors?kind=vm",
+		mocks.CreateResponder(200, string(flavorsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	for id, vms := range hostVMs {
+		vmsResponse, err := json.Marshal(photon.VMs{Items: vms})
+		if err != nil {
+			t.Error("Not expecting error serializing vms")
+		}
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/hosts/"+id+"/vms",
+			mocks.CreateResponder(200, string(vmsResponse[:])))
+	}
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.String("output", "csv", "output")
+	err = globalSet.Parse([]string{"--output", "csv"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("by-zone", false, "by zone")
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	var buf bytes.Buffer
+	err = hostReport(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting host report to fail: ", err)
+	}
+	expected := "hostId,address,availabilityZone,esxVersion,tags,vms,started,stopped,suspended,error,cpu,memoryGB\n" +
+		"host-1,10.0.0.1,az1,6.0.0,\"CLOUD,MGMT\",2,1,1,0,0,5,8.5\n" +
+		"host-2,10.0.0.2,az1,6.0.0,CLOUD,1,1,0,0,0,1,0.5\n" +
+		"host-3,10.0.0.3,,5.5.0,CLOUD,0,0,0,0,0,0,0\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected host report:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.Bool("by-zone", true, "by zone")
+	cxt = cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	buf.Reset()
+	err = hostReport(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting host report to fail: ", err)
+	}
+	expected = "availabilityZone,hosts,vms,started,stopped,suspended,error,cpu,memoryGB\n" +
+		",1,0,0,0,0,0,0,0\n" +
+		"az1,2,3,2,1,0,0,6,9\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected availability zone report:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
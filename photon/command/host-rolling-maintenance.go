@@ -0,0 +1,273 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// Outcome of the maintenance of one host
+type maintenanceResult struct {
+	HostID  string `json:"hostId"`
+	Address string `json:"address"`
+	Result  string `json:"result"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Cycles the selected hosts through maintenance mode, at most --max-unavailable at a time.
+// Every host is drained, the --exec hook is run and the host is brought back to READY. The VMs
+// stopped by --policy stop are started again.
+// No host is started after the first failure.
+func rollingMaintenance(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 0, "host rolling-maintenance [<options>]")
+	if err != nil {
+		return err
+	}
+	policy := c.String("policy")
+	if policy != drainPolicyStop && policy != drainPolicyFail {
+		return fmt.Errorf("Unknown policy '%s', use %s or %s", policy, drainPolicyStop, drainPolicyFail)
+	}
+	maxUnavailable := c.Int("max-unavailable")
+	if maxUnavailable < 1 {
+		return errors.New("--max-unavailable must be at least 1")
+	}
+	isScripting := utils.IsNonInteractive(c)
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := selectMaintenanceHosts(c.String("deployment_id"), c.String("tag"), c.String("availability-zone"))
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return errors.New("No host matches the selection")
+	}
+	for _, host := range hosts {
+		if host.State != "READY" {
+			return fmt.Errorf("Host %s (%s) is in state %s, all selected hosts must be READY",
+				host.Address, host.ID, host.State)
+		}
+	}
+
+	if !isScripting {
+		fmt.Fprintf(w, "%d hosts will be put into maintenance, %d at a time:\n", len(hosts), maxUnavailable)
+		for _, host := range hosts {
+			fmt.Fprintf(w, "  %s (%s)\n", host.Address, host.ID)
+		}
+	}
+	if !confirmed(isScripting) {
+		fmt.Fprintln(w, "OK. Canceled")
+		return nil
+	}
+
+	results := runMaintenance(hosts, maxUnavailable, policy, c.String("exec"), c.Duration("timeout"), isScripting)
+	err = printMaintenanceResults(results, w, c)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if len(result.Error) != 0 {
+			return fmt.Errorf("Rolling maintenance stopped, host %s failed", result.Address)
+		}
+	}
+	return nil
+}
+
+// Hosts of the deployment with the tag and in the availability zone, given by name or ID
+func selectMaintenanceHosts(deploymentID string, tag string, availabilityZone string) ([]photon.Host, error) {
+	deploymentID, err := findDeploymentID(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneID := ""
+	if len(availabilityZone) != 0 {
+		zone, err := findAvailabilityZone(availabilityZone)
+		if err != nil {
+			return nil, err
+		}
+		zoneID = zone.ID
+	}
+
+	hosts, err := client.Esxclient.Deployments.GetHosts(deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []photon.Host
+	for _, host := range hosts.Items {
+		if len(zoneID) != 0 && host.AvailabilityZone != zoneID {
+			continue
+		}
+		if len(tag) != 0 && !contains(host.Tags, tag) {
+			continue
+		}
+		selected = append(selected, host)
+	}
+	return selected, nil
+}
+
+func runMaintenance(hosts []photon.Host, maxUnavailable int, policy string, hook string, timeout time.Duration,
+	isScripting bool) []maintenanceResult {
+	results := make([]maintenanceResult, len(hosts))
+	var mutex sync.Mutex
+	stopped := false
+
+	runInParallel(len(hosts), maxUnavailable, "MAINTENANCE", true, func(i int) error {
+		host := hosts[i]
+		results[i] = maintenanceResult{HostID: host.ID, Address: host.Address, Result: "skipped"}
+
+		mutex.Lock()
+		skip := stopped
+		mutex.Unlock()
+		if skip {
+			return nil
+		}
+
+		err := maintainHost(host, policy, hook, timeout, isScripting)
+		if err != nil {
+			mutex.Lock()
+			stopped = true
+			mutex.Unlock()
+			results[i].Result = "failed"
+			results[i].Error = err.Error()
+			return err
+		}
+		results[i].Result = "done"
+		return nil
+	})
+	return results
+}
+
+func maintainHost(host photon.Host, policy string, hook string, timeout time.Duration, isScripting bool) error {
+	if !isScripting {
+		fmt.Printf("Draining host %s\n", host.Address)
+	}
+	drained, err := drainHost(host.ID, policy, timeout, true)
+	if err != nil {
+		return fmt.Errorf("drain: %s", err)
+	}
+
+	if len(hook) != 0 {
+		if !isScripting {
+			fmt.Printf("Running '%s' for host %s\n", hook, host.Address)
+		}
+		err = runMaintenanceHook(hook, host, isScripting)
+		if err != nil {
+			return fmt.Errorf("hook: %s", err)
+		}
+	}
+
+	if !isScripting {
+		fmt.Printf("Returning host %s to service\n", host.Address)
+	}
+	err = undrainHost(host.ID)
+	if err != nil {
+		return fmt.Errorf("undrain: %s", err)
+	}
+	err = waitForHostState(host.ID, "READY", timeout)
+	if err != nil {
+		return err
+	}
+	return startDrainedVMs(drained, isScripting)
+}
+
+// Starts the VMs the drain stopped once their host is back in service
+func startDrainedVMs(drained []drainResult, isScripting bool) error {
+	failed := 0
+	for _, result := range drained {
+		if !isVMRunning(result.State) || len(result.Error) != 0 {
+			continue
+		}
+		if !isScripting {
+			fmt.Printf("Starting VM %s (%s)\n", result.Name, result.VMID)
+		}
+		err := waitForTask(client.Esxclient.VMs.Start(result.VMID))
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("start: %d stopped VMs could not be started again", failed)
+	}
+	return nil
+}
+
+// Runs the hook with the shell, the host is passed in PHOTON_HOST_ADDRESS and PHOTON_HOST_ID.
+// Scripts get the output of the hook on stderr to keep the results parsable.
+func runMaintenanceHook(hook string, host photon.Host, isScripting bool) error {
+	cmd := exec.Command("/bin/sh", "-c", hook)
+	cmd.Env = append(os.Environ(), "PHOTON_HOST_ADDRESS="+host.Address, "PHOTON_HOST_ID="+host.ID)
+	cmd.Stdout = os.Stdout
+	if isScripting {
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func waitForHostState(id string, state string, timeout time.Duration) error {
+	start := time.Now()
+	for {
+		host, err := client.Esxclient.Hosts.Get(id)
+		if err != nil {
+			return err
+		}
+		if host.State == state {
+			return nil
+		}
+		if host.State == "ERROR" {
+			return fmt.Errorf("host %s is in state ERROR", id)
+		}
+		if time.Since(start) > timeout {
+			return fmt.Errorf("timed out waiting for host %s to be %s, it is %s", id, state, host.State)
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+func printMaintenanceResults(results []maintenanceResult, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.HostID, result.Address, result.Result, result.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(results, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Host ID\tIP\tResult\tError\n")
+		for _, result := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.HostID, result.Address, result.Result,
+				valueOrDash(result.Error))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(results))
+	}
+	return nil
+}
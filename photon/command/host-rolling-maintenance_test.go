@@ -0,0 +1,175 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestRollingMaintenance(t *testing.T) {
+	hosts := []photon.Host{
+		{ID: "host-1", Address: "10.0.0.1", State: "READY", Tags: []string{"CLOUD"}, AvailabilityZone: "zone-1"},
+		{ID: "host-2", Address: "10.0.0.2", State: "READY", Tags: []string{"CLOUD"}, AvailabilityZone: "zone-1"},
+		{ID: "host-3", Address: "10.0.0.3", State: "READY", Tags: []string{"MGMT"}, AvailabilityZone: "zone-1"},
+		{ID: "host-4", Address: "10.0.0.4", State: "READY", Tags: []string{"CLOUD"}, AvailabilityZone: "zone-2"},
+	}
+	deploymentsResponse, err := json.Marshal(photon.Deployments{Items: []photon.Deployment{{ID: "deployment-ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	zonesResponse, err := json.Marshal(photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}, {ID: "zone-2", Name: "az2"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	hostsResponse, err := json.Marshal(MockHostsPage{Items: hosts})
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	vmsResponse, err := json.Marshal(photon.VMs{})
+	if err != nil {
+		t.Error("Not expecting error serializing vms")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	for _, host := range hosts {
+		hostResponse, err := json.Marshal(host)
+		if err != nil {
+			t.Error("Not expecting error serializing host")
+		}
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/hosts/"+host.ID,
+			mocks.CreateResponder(200, string(hostResponse[:])))
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/hosts/"+host.ID+"/vms",
+			mocks.CreateResponder(200, string(vmsResponse[:])))
+		for _, operation := range []string{"suspend", "enter_maintenance"} {
+			task := photon.Task{ID: host.ID + "-" + operation, State: "COMPLETED", Entity: photon.Entity{ID: host.ID}}
+			taskResponse, err := json.Marshal(task)
+			if err != nil {
+				t.Error("Not expecting error serializing task")
+			}
+			mocks.RegisterResponder(
+				"POST",
+				server.URL+"/hosts/"+host.ID+"/"+operation,
+				mocks.CreateResponder(200, string(taskResponse[:])))
+			mocks.RegisterResponder(
+				"GET",
+				server.URL+"/tasks/"+task.ID,
+				mocks.CreateResponder(200, string(taskResponse[:])))
+		}
+	}
+
+	// host-1 runs a VM, which is stopped with --policy stop and started once the host is back
+	var vmCalls []string
+	vmStopped := false
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/hosts/host-1/vms",
+		func(req *http.Request) (*http.Response, error) {
+			vm := photon.VM{ID: "vm-1", Name: "web", State: "STARTED"}
+			if vmStopped {
+				vm.State = "STOPPED"
+			}
+			response, _ := json.Marshal(photon.VMs{Items: []photon.VM{vm}})
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+	for _, operation := range []string{"stop", "start"} {
+		operation := operation
+		task := photon.Task{ID: "vm-1-" + operation, State: "COMPLETED", Entity: photon.Entity{ID: "vm-1"}}
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/vm-1/"+operation,
+			func(req *http.Request) (*http.Response, error) {
+				vmCalls = append(vmCalls, operation)
+				vmStopped = operation == "stop"
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	newContext := func(hook string) *cli.Context {
+		set := flag.NewFlagSet("test", 0)
+		set.String("tag", "CLOUD", "tag")
+		set.String("availability-zone", "az1", "availability zone")
+		set.Int("max-unavailable", 1, "max unavailable")
+		set.String("exec", hook, "exec")
+		set.String("policy", drainPolicyStop, "policy")
+		set.Duration("timeout", time.Minute, "timeout")
+		return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	}
+
+	var buf bytes.Buffer
+	err = rollingMaintenance(newContext("test -n \"$PHOTON_HOST_ADDRESS\""), &buf)
+	if err != nil {
+		t.Error("Not expecting rolling maintenance to fail: ", err)
+	}
+	if buf.String() != "host-1\t10.0.0.1\tdone\t\nhost-2\t10.0.0.2\tdone\t\n" {
+		t.Errorf("Unexpected rolling maintenance results: %s", buf.String())
+	}
+	if strings.Join(vmCalls, ",") != "stop,start" {
+		t.Errorf("Expected the VM to be stopped and started again, got %v", vmCalls)
+	}
+
+	// The hook fails for the first host, so the second one is not touched
+	buf.Reset()
+	err = rollingMaintenance(newContext("test \"$PHOTON_HOST_ADDRESS\" != 10.0.0.1"), &buf)
+	if err == nil {
+		t.Error("Expected rolling maintenance to fail")
+	}
+	if !strings.Contains(buf.String(), "host-1\t10.0.0.1\tfailed\thook") ||
+		!strings.Contains(buf.String(), "host-2\t10.0.0.2\tskipped\t") {
+		t.Errorf("Unexpected rolling maintenance results: %s", buf.String())
+	}
+}
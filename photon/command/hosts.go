@@ -16,6 +16,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
 	"github.com/vmware/photon-controller-cli/photon/utils"
@@ -28,6 +29,7 @@ import (
 
 // Creates a cli.Command for host
 // Subcommands: create;                Usage: host create [<options>]
+//              import;                Usage: host import --file <file> [<options>]
 //              delete;                Usage: host delete <id>
 //              show;                  Usage: host show <id>
 //              list;                  Usage: host list
@@ -38,6 +40,10 @@ import (
 //              resume;                Usage: host resume <id>
 //              enter-maintenance;     Usage: host enter-maintenance <id>
 //              exit-maintenance;      Usage: host exit-maintenance <id>
+//              drain;                 Usage: host drain <id> [<options>]
+//              undrain;               Usage: host undrain <id>
+//              rolling-maintenance;   Usage: host rolling-maintenance [<options>]
+//              report;                Usage: host report [<options>]
 func GetHostsCommand() cli.Command {
 	command := cli.Command{
 		Name:  "host",
@@ -83,6 +89,39 @@ func GetHostsCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "import",
+				Usage: "Create the hosts listed in a CSV or JSON inventory file",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "file, f",
+						Usage: "inventory file",
+					},
+					cli.StringFlag{
+						Name:  "format",
+						Usage: "format of the inventory file, csv or json (default: from the file extension)",
+					},
+					cli.StringFlag{
+						Name:  "deployment_id, d",
+						Usage: "deployment id to create the hosts in",
+					},
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: 10,
+						Usage: "number of hosts to create at the same time",
+					},
+					cli.BoolFlag{
+						Name:  "retry-failed",
+						Usage: "retry the creation of hosts that failed once more",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := importHosts(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "delete",
 				Usage: "Delete a host with specified id",
@@ -189,6 +228,97 @@ func GetHostsCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "drain",
+				Usage: "Suspend host, stop or report its VMs and enter maintenance mode",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "policy",
+						Value: drainPolicyFail,
+						Usage: "what to do with running VMs: stop them or fail",
+					},
+					cli.DurationFlag{
+						Name:  "timeout",
+						Value: 30 * time.Minute,
+						Usage: "how long to wait for the VMs to stop",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := drainHostCommand(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "undrain",
+				Usage: "Exit maintenance mode and resume a drained host",
+				Action: func(c *cli.Context) {
+					err := undrainHostCommand(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "rolling-maintenance",
+				Usage: "Cycle the selected hosts through maintenance mode",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "tag, t",
+						Usage: "only hosts with this tag",
+					},
+					cli.StringFlag{
+						Name:  "availability-zone, z",
+						Usage: "only hosts in this availability zone, name or id",
+					},
+					cli.IntFlag{
+						Name:  "max-unavailable",
+						Value: 1,
+						Usage: "number of hosts in maintenance at the same time",
+					},
+					cli.StringFlag{
+						Name:  "exec, e",
+						Usage: "command to run while a host is in maintenance, PHOTON_HOST_ADDRESS holds its address",
+					},
+					cli.StringFlag{
+						Name:  "policy",
+						Value: drainPolicyFail,
+						Usage: "what to do with running VMs: stop them and start them once the host is ready, or fail",
+					},
+					cli.DurationFlag{
+						Name:  "timeout",
+						Value: 30 * time.Minute,
+						Usage: "how long to wait for the VMs to stop and for the host to be ready",
+					},
+					cli.StringFlag{
+						Name:  "deployment_id, d",
+						Usage: "deployment id, required if there are multiple deployments",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := rollingMaintenance(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "report",
+				Usage: "Show the VMs and the CPU and memory used per host and availability zone",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "by-zone",
+						Usage: "only show the totals per availability zone",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := hostReport(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 		},
 	}
 	return command
@@ -293,6 +423,11 @@ func deleteHost(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	deleteTask, err := client.Esxclient.Hosts.Delete(id)
 	if err != nil {
 		return err
@@ -358,6 +493,11 @@ func showHost(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	host, err := client.Esxclient.Hosts.Get(id)
 	if err != nil {
 		return err
@@ -401,6 +541,15 @@ func setHostAvailabilityZone(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+	availabilityZoneId, err = resolveAvailabilityZoneID(availabilityZoneId)
+	if err != nil {
+		return err
+	}
+
 	setAvailabilityZoneSpec := photon.HostSetAvailabilityZoneOperation{}
 	setAvailabilityZoneSpec.AvailabilityZoneId = availabilityZoneId
 	setTask, err := client.Esxclient.Hosts.SetAvailabilityZone(id, &setAvailabilityZoneSpec)
@@ -439,6 +588,11 @@ func getHostTasks(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	taskList, err := client.Esxclient.Hosts.GetTasks(id, options)
 	if err != nil {
 		return err
@@ -463,6 +617,11 @@ func listHostVMs(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	vmList, err := client.Esxclient.Hosts.GetVMs(id)
 	if err != nil {
 		return err
@@ -490,6 +649,11 @@ func suspendHost(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	suspendTask, err := client.Esxclient.Hosts.Suspend(id)
 	if err != nil {
 		return err
@@ -516,6 +680,11 @@ func resumeHost(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	resumeTask, err := client.Esxclient.Hosts.Resume(id)
 	if err != nil {
 		return err
@@ -542,6 +711,11 @@ func enterMaintenanceMode(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	enterTask, err := client.Esxclient.Hosts.EnterMaintenanceMode(id)
 	if err != nil {
 		return err
@@ -568,6 +742,11 @@ func exitMaintenanceMode(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveHostID(id)
+	if err != nil {
+		return err
+	}
+
 	exitTask, err := client.Esxclient.Hosts.ExitMaintenanceMode(id)
 	if err != nil {
 		return err
@@ -62,6 +62,7 @@ func TestCreateDeleteHost(t *testing.T) {
 		mocks.CreateResponder(200, string(taskresponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "fake-host-id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -363,6 +364,8 @@ func TestSetHostAvailabilityZone(t *testing.T) {
 		mocks.CreateResponder(200, string(taskResponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "fake-host-id")
+	registerResolveList(server.URL+"/availabilityzones", "fake-availability-zone-id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -419,6 +422,7 @@ func TestHostTasks(t *testing.T) {
 
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -471,6 +475,7 @@ func TestHostGetVMs(t *testing.T) {
 		mocks.CreateResponder(200, string(response[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -521,6 +526,7 @@ func TestSuspendAndResumeHost(t *testing.T) {
 		mocks.CreateResponder(200, string(taskResponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "fake-host-id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -612,6 +618,7 @@ func TestEnterAndExitMaintenanceMode(t *testing.T) {
 		mocks.CreateResponder(200, string(taskResponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/hosts", "fake-host-id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
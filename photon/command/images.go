@@ -222,6 +222,11 @@ func deleteImage(c *cli.Context) error {
 			return err
 		}
 
+		id, err = resolveImageID(id)
+		if err != nil {
+			return err
+		}
+
 		deleteTask, err := client.Esxclient.Images.Delete(id)
 		if err != nil {
 			return err
@@ -305,6 +310,11 @@ func showImage(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveImageID(id)
+	if err != nil {
+		return err
+	}
+
 	image, err := client.Esxclient.Images.Get(id)
 	if err != nil {
 		return err
@@ -356,6 +366,11 @@ func getImageTasks(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveImageID(id)
+	if err != nil {
+		return err
+	}
+
 	taskList, err := client.Esxclient.Images.GetTasks(id, options)
 	if err != nil {
 		return err
@@ -62,6 +62,7 @@ func TestCreateDeleteImage(t *testing.T) {
 		mocks.CreateResponder(200, string(taskresponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/images", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -348,6 +349,7 @@ func TestImageTasks(t *testing.T) {
 
 	defer server.Close()
 
+	registerResolveList(server.URL+"/images", "1")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
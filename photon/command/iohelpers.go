@@ -132,16 +132,44 @@ func confirmed(isScripting bool) bool {
 
 // Prints out the output of tasks
 func printTaskList(taskList []photon.Task, c *cli.Context) error {
-	if c.GlobalIsSet("non-interactive") {
+	printer := newTaskListPrinter(os.Stdout, c)
+	err := printer.printPage(taskList)
+	if err != nil {
+		return err
+	}
+	return printer.done()
+}
+
+// Prints a list of tasks one page at a time
+type taskListPrinter struct {
+	w     io.Writer
+	c     *cli.Context
+	list  *utils.ListWriter
+	table *tabwriter.Writer
+	count int
+}
+
+func newTaskListPrinter(w io.Writer, c *cli.Context) *taskListPrinter {
+	table := new(tabwriter.Writer)
+	table.Init(w, 4, 4, 2, ' ', 0)
+	return &taskListPrinter{w: w, c: c, list: utils.NewListWriter(w, c), table: table}
+}
+
+func (p *taskListPrinter) printPage(taskList []photon.Task) error {
+	if p.c.GlobalIsSet("non-interactive") {
 		for _, task := range taskList {
-			fmt.Printf("%s\t%s\t%s\t%d\t%d\n", task.ID, task.State, task.Operation, task.StartedTime, task.EndTime-task.StartedTime)
+			fmt.Fprintf(p.w, "%s\t%s\t%s\t%d\t%d\n", task.ID, task.State, task.Operation, task.StartedTime, task.EndTime-task.StartedTime)
+		}
+	} else if utils.NeedsFormatting(p.c) {
+		err := p.list.Write(taskList)
+		if err != nil {
+			return err
 		}
-	} else if utils.NeedsFormatting(c) {
-		utils.FormatObjects(taskList, os.Stdout, c)
 	} else {
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
-		fmt.Fprintf(w, "\nTask\tStart Time\tDuration\n")
+		w := p.table
+		if p.count == 0 {
+			fmt.Fprintf(w, "\nTask\tStart Time\tDuration\n")
+		}
 
 		for _, task := range taskList {
 			var duration int64
@@ -156,14 +184,24 @@ func printTaskList(taskList []photon.Task, c *cli.Context) error {
 			if err != nil {
 				return err
 			}
-			fmt.Printf("%s, %s\n", task.Operation, task.State)
-		}
-		if len(taskList) > 0 {
-			fmt.Printf("\nYou can run 'photon task show <id>' for more information\n")
+			fmt.Fprintf(p.w, "%s, %s\n", task.Operation, task.State)
 		}
-		fmt.Printf("Total: %d\n", len(taskList))
 	}
+	p.count += len(taskList)
+	return nil
+}
 
+func (p *taskListPrinter) done() error {
+	if p.c.GlobalIsSet("non-interactive") {
+		return nil
+	}
+	if utils.NeedsFormatting(p.c) {
+		return p.list.Close()
+	}
+	if p.count > 0 {
+		fmt.Fprintf(p.w, "\nYou can run 'photon task show <id>' for more information\n")
+	}
+	fmt.Fprintf(p.w, "Total: %d\n", p.count)
 	return nil
 }
 
@@ -285,40 +323,168 @@ func askForVMDiskList(disksList []photon.AttachedDisk) ([]photon.AttachedDisk, e
 }
 
 func printVMList(vmList []photon.VM, w io.Writer, c *cli.Context, summaryView bool) error {
-	stateCount := make(map[string]int)
-	for _, vm := range vmList {
-		stateCount[vm.State]++
+	printer := newVMListPrinter(w, c, summaryView, false, false)
+	items := make([]vmListItem, len(vmList))
+	for i, vm := range vmList {
+		items[i].VM = vm
 	}
+	err := printer.printPage(items)
+	if err != nil {
+		return err
+	}
+	return printer.done()
+}
 
-	if c.GlobalIsSet("non-interactive") {
-		if !summaryView {
-			for _, vm := range vmList {
-				fmt.Printf("%s\t%s\t%s\n", vm.ID, vm.Name, vm.State)
+// A VM of a list, with the name of its project when listing the VMs of every project
+type vmListItem struct {
+	photon.VM
+	Project string `json:"project"`
+}
+
+// Prints a list of VMs one page at a time, followed by the number of VMs in every state.
+// The wide list adds the placement, image and boot disk of the VMs.
+type vmListPrinter struct {
+	w             io.Writer
+	c             *cli.Context
+	summaryView   bool
+	wide          bool
+	allProjects   bool
+	list          *utils.ListWriter
+	table         *tabwriter.Writer
+	count         int
+	stateCount    map[string]int
+	headerPrinted bool
+	imageNames    map[string]string
+}
+
+func newVMListPrinter(w io.Writer, c *cli.Context, summaryView bool, wide bool, allProjects bool) *vmListPrinter {
+	table := new(tabwriter.Writer)
+	table.Init(w, 4, 4, 2, ' ', 0)
+	return &vmListPrinter{
+		w:           w,
+		c:           c,
+		summaryView: summaryView,
+		wide:        wide,
+		allProjects: allProjects,
+		list:        utils.NewListWriter(w, c),
+		table:       table,
+		stateCount:  make(map[string]int),
+	}
+}
+
+func (p *vmListPrinter) printPage(items []vmListItem) error {
+	for _, item := range items {
+		p.stateCount[item.State]++
+	}
+
+	if p.c.GlobalIsSet("non-interactive") {
+		if !p.summaryView {
+			for _, item := range items {
+				fmt.Fprintf(p.w, "%s\n", strings.Join(p.getRow(item, true), "\t"))
 			}
 		}
-	} else if c.GlobalString("output") != "" {
-		utils.FormatObjects(vmList, w, c)
-	} else {
-		if !summaryView {
-			w := new(tabwriter.Writer)
-			w.Init(os.Stdout, 4, 4, 2, ' ', 0)
-			fmt.Fprintf(w, "ID\tName\tState\n")
-			for _, vm := range vmList {
-				fmt.Fprintf(w, "%s\t%s\t%s\n", vm.ID, vm.Name, vm.State)
+	} else if p.c.GlobalString("output") != "" {
+		var err error
+		if p.allProjects {
+			err = p.list.Write(items)
+		} else {
+			vmList := make([]photon.VM, len(items))
+			for i, item := range items {
+				vmList[i] = item.VM
 			}
-			err := w.Flush()
-			if err != nil {
-				return err
+			err = p.list.Write(vmList)
+		}
+		if err != nil {
+			return err
+		}
+	} else if !p.summaryView && len(items) != 0 {
+		if !p.headerPrinted {
+			fmt.Fprintf(p.table, "%s\n", strings.Join(p.getHeader(), "\t"))
+			p.headerPrinted = true
+		}
+		for _, item := range items {
+			fmt.Fprintf(p.table, "%s\n", strings.Join(p.getRow(item, false), "\t"))
+		}
+		err := p.table.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	p.count += len(items)
+	return nil
+}
+
+func (p *vmListPrinter) getHeader() []string {
+	header := []string{"ID", "Name", "State"}
+	if p.allProjects {
+		header = append([]string{"Project"}, header...)
+	}
+	if p.wide {
+		header = append(header, "Flavor", "Host", "Datastore", "Image", "Boot Disk")
+	}
+	return header
+}
+
+// Scripts get image IDs, people get image names
+func (p *vmListPrinter) getRow(item vmListItem, isScripting bool) []string {
+	row := []string{item.ID, item.Name, item.State}
+	if p.allProjects {
+		row = append([]string{item.Project}, row...)
+	}
+	if !p.wide {
+		return row
+	}
+
+	image := item.SourceImageID
+	if !isScripting {
+		if p.imageNames == nil {
+			p.imageNames = make(map[string]string)
+			images, err := client.Esxclient.Images.GetAll(nil)
+			if err == nil {
+				for _, image := range images.Items {
+					p.imageNames[image.ID] = image.Name
+				}
 			}
 		}
-		fmt.Printf("\nTotal: %d\n", len(vmList))
-		for key, value := range stateCount {
-			fmt.Printf("%s: %d\n", key, value)
+		if name, ok := p.imageNames[image]; ok {
+			image = name
 		}
 	}
+	bootDisk := ""
+	for _, disk := range item.AttachedDisks {
+		if disk.BootDisk {
+			bootDisk = disk.Name
+			break
+		}
+	}
+	return append(row, valueOrDash(item.Flavor), valueOrDash(item.Host), valueOrDash(item.Datastore),
+		valueOrDash(image), valueOrDash(bootDisk))
+}
+
+func (p *vmListPrinter) done() error {
+	if p.c.GlobalIsSet("non-interactive") {
+		return nil
+	}
+	if p.c.GlobalString("output") != "" {
+		return p.list.Close()
+	}
+	fmt.Fprintf(p.w, "\nTotal: %d\n", p.count)
+	for key, value := range p.stateCount {
+		fmt.Fprintf(p.w, "%s: %d\n", key, value)
+	}
 	return nil
 }
 
+// Sorts VMs by the value of a column
+type vmListItemsBy struct {
+	items []vmListItem
+	key   func(item vmListItem) string
+}
+
+func (s vmListItemsBy) Len() int           { return len(s.items) }
+func (s vmListItemsBy) Swap(i, j int)      { s.items[i], s.items[j] = s.items[j], s.items[i] }
+func (s vmListItemsBy) Less(i, j int) bool { return s.key(s.items[i]) < s.key(s.items[j]) }
+
 func printClusterList(clusterList []photon.Cluster, w io.Writer, c *cli.Context, summaryView bool) error {
 	stateCount := make(map[string]int)
 	for _, cluster := range clusterList {
@@ -418,7 +584,7 @@ func getVMNetworks(id string, isScripting bool) (networks []interface{}, err err
 	return networks, nil
 }
 
-func printVMNetworks(networks []interface{}, isScripting bool) error {
+func printVMNetworks(networks []interface{}, out io.Writer, isScripting bool) error {
 	networkName := "-"
 	macAddr := "-"
 	ipAddr := "-"
@@ -426,7 +592,7 @@ func printVMNetworks(networks []interface{}, isScripting bool) error {
 	isConnected := "-"
 	w := new(tabwriter.Writer)
 	if !isScripting {
-		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		w.Init(out, 4, 4, 2, ' ', 0)
 		fmt.Fprintf(w, "Network\tMAC Address\tIP Address\tNetmask\tIsConnected\n")
 	}
 	for _, nt := range networks {
@@ -447,7 +613,7 @@ func printVMNetworks(networks []interface{}, isScripting bool) error {
 			isConnected = val.(string)
 		}
 		if isScripting {
-			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", networkName, macAddr, ipAddr, netMask, isConnected)
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", networkName, macAddr, ipAddr, netMask, isConnected)
 		} else {
 			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", networkName, macAddr, ipAddr, netMask, isConnected)
 		}
@@ -457,7 +623,7 @@ func printVMNetworks(networks []interface{}, isScripting bool) error {
 		if err != nil {
 			return err
 		}
-		fmt.Printf("\nTotal: %d\n", len(networks))
+		fmt.Fprintf(out, "\nTotal: %d\n", len(networks))
 	}
 	return nil
 }
@@ -508,6 +674,25 @@ func findTenantID(name string) (string, error) {
 	return id, nil
 }
 
+// Returns the deployment ID if it is not empty, otherwise the ID of the only deployment
+func findDeploymentID(id string) (string, error) {
+	if len(id) != 0 {
+		return id, nil
+	}
+
+	deployments, err := client.Esxclient.Deployments.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if len(deployments.Items) == 0 {
+		return "", fmt.Errorf("There are no deployments")
+	}
+	if len(deployments.Items) > 1 {
+		return "", fmt.Errorf("There are multiple deployments, please specify the deployment ID")
+	}
+	return deployments.Items[0].ID, nil
+}
+
 // Verifies and gets tenant name and id for commands specifying tenant
 // Returns tenant in config file if name is empty
 func verifyTenant(name string) (*cf.TenantConfiguration, error) {
@@ -725,6 +910,79 @@ func pollTaskWithTimeout(api *photon.Client, id string, pollTimeout time.Duratio
 	return
 }
 
+// Runs op for each of the count items, with at most parallel of them running at once.
+// Unless isScripting, a single progress line summarizes how many items are done.
+// Returns the error of each item. op must not call pollTask, whose progress display
+// is not safe for concurrent use; use Tasks.Wait instead.
+func runInParallel(count int, parallel int, operation string, isScripting bool, op func(i int) error) []error {
+	errs := make([]error, count)
+	if count == 0 {
+		return errs
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var mutex sync.Mutex
+	done := 0
+	failed := 0
+
+	start := time.Now()
+	stopDisplay := make(chan bool)
+	var displayWg sync.WaitGroup
+	if !isScripting {
+		displayWg.Add(1)
+		go func() {
+			defer displayWg.Done()
+			for {
+				mutex.Lock()
+				progress := fmt.Sprintf("%d/%d done, %d failed", done, count, failed)
+				cursor := done * 20 / count
+				mutex.Unlock()
+
+				fmt.Printf("\r%s\r", strings.Repeat(" ", 100))
+				elapsed := int(time.Since(start).Seconds())
+				fmt.Printf("%2dh%2dm%2ds ", elapsed/3600, (elapsed/60)%60, elapsed%60)
+				fmt.Printf("[%s] %s : %s", getProgressBar(cursor, 20), operation, progress)
+
+				select {
+				case <-stopDisplay:
+					fmt.Printf("\r%s\r", strings.Repeat(" ", 100))
+					return
+				case <-time.After(500 * time.Millisecond):
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan bool, parallel)
+	for i := 0; i < count; i++ {
+		slots <- true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := op(i)
+			<-slots
+
+			mutex.Lock()
+			errs[i] = err
+			done++
+			if err != nil {
+				failed++
+			}
+			mutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if !isScripting {
+		close(stopDisplay)
+		displayWg.Wait()
+	}
+	return errs
+}
+
 func findStartedStep(task *photon.Task) *photon.Step {
 	for i := 0; task != nil && i < len(task.Steps); i++ {
 		if task.Steps[i].State == "STARTED" {
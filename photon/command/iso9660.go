@@ -0,0 +1,370 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A minimal ISO9660 writer for the small images handed to guests, like cloud-init seeds.
+// The image has a primary directory tree with ISO9660 names and a Joliet tree keeping the
+// original names, which is the tree Linux and Windows read when it is present.
+
+const isoSectorSize = 2048
+
+// The two directory trees of an image
+const (
+	isoPrimaryTree = iota
+	isoJolietTree
+)
+
+// A file of an image, Path is slash separated, e.g. "openstack/latest/user_data"
+type isoFile struct {
+	Path string
+	Data []byte
+}
+
+type isoNode struct {
+	name     string
+	parent   *isoNode
+	children []*isoNode
+	isDir    bool
+	data     []byte
+	// Location and size of the extent in each tree. Files share their extent between the trees.
+	sector [2]uint32
+	size   [2]uint32
+}
+
+// Writes an ISO9660 image with the given volume label and files
+func writeISO9660(w io.Writer, volumeID string, files []isoFile, modTime time.Time) error {
+	root := &isoNode{isDir: true}
+	root.parent = root
+	for _, file := range files {
+		err := root.add(strings.Split(strings.Trim(file.Path, "/"), "/"), file.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// System area, 3 volume descriptors, then the path tables, the directories and the files
+	sector := uint32(16 + 3)
+	var dirs [2][]*isoNode
+	var pathTableSize, pathTableL, pathTableM [2]uint32
+	for tree := range dirs {
+		dirs[tree] = root.dirs(tree)
+		pathTableSize[tree] = isoPathTableSize(dirs[tree], tree)
+		sectors := isoSectors(pathTableSize[tree])
+		pathTableL[tree] = sector
+		pathTableM[tree] = sector + sectors
+		sector += 2 * sectors
+	}
+	for tree := range dirs {
+		for _, dir := range dirs[tree] {
+			dir.sector[tree] = sector
+			dir.size[tree] = dir.extentSize(tree)
+			sector += isoSectors(dir.size[tree])
+		}
+	}
+	for _, dir := range dirs[isoPrimaryTree] {
+		for _, node := range dir.children {
+			if node.isDir {
+				continue
+			}
+			node.sector = [2]uint32{sector, sector}
+			node.size = [2]uint32{uint32(len(node.data)), uint32(len(node.data))}
+			sector += isoSectors(uint32(len(node.data)))
+		}
+	}
+
+	image := make([]byte, sector*isoSectorSize)
+	for tree := range dirs {
+		descriptor := image[(16+tree)*isoSectorSize:]
+		writeISOVolumeDescriptor(descriptor, tree, volumeID, sector, root, modTime)
+		putBothEndian32(descriptor[132:], pathTableSize[tree])
+		binary.LittleEndian.PutUint32(descriptor[140:], pathTableL[tree])
+		binary.BigEndian.PutUint32(descriptor[148:], pathTableM[tree])
+
+		writeISOPathTable(image[pathTableL[tree]*isoSectorSize:], dirs[tree], tree, binary.LittleEndian)
+		writeISOPathTable(image[pathTableM[tree]*isoSectorSize:], dirs[tree], tree, binary.BigEndian)
+		for _, dir := range dirs[tree] {
+			dir.writeExtent(image[dir.sector[tree]*isoSectorSize:], tree, modTime)
+		}
+	}
+	terminator := image[18*isoSectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:], "CD001")
+	terminator[6] = 1
+	for _, dir := range dirs[isoPrimaryTree] {
+		for _, node := range dir.children {
+			if !node.isDir {
+				copy(image[node.sector[isoPrimaryTree]*isoSectorSize:], node.data)
+			}
+		}
+	}
+
+	_, err := w.Write(image)
+	return err
+}
+
+func (dir *isoNode) add(path []string, data []byte) error {
+	for _, child := range dir.children {
+		if child.name != path[0] {
+			continue
+		}
+		if len(path) == 1 || !child.isDir {
+			return fmt.Errorf("Duplicate path '%s' in ISO image", path[0])
+		}
+		return child.add(path[1:], data)
+	}
+	if len(path[0]) == 0 {
+		return fmt.Errorf("Invalid empty name in ISO image")
+	}
+	child := &isoNode{name: path[0], parent: dir, isDir: len(path) > 1, data: data}
+	dir.children = append(dir.children, child)
+	if child.isDir {
+		return child.add(path[1:], data)
+	}
+	return nil
+}
+
+// Returns the directories in path table order: by level, then parent, then identifier
+func (root *isoNode) dirs(tree int) []*isoNode {
+	dirs := []*isoNode{root}
+	for i := 0; i < len(dirs); i++ {
+		for _, child := range dirs[i].sortedChildren(tree) {
+			if child.isDir {
+				dirs = append(dirs, child)
+			}
+		}
+	}
+	return dirs
+}
+
+func (dir *isoNode) sortedChildren(tree int) []*isoNode {
+	children := append([]*isoNode{}, dir.children...)
+	sort.Sort(isoNodesByIdentifier{children, tree})
+	return children
+}
+
+type isoNodesByIdentifier struct {
+	nodes []*isoNode
+	tree  int
+}
+
+func (s isoNodesByIdentifier) Len() int      { return len(s.nodes) }
+func (s isoNodesByIdentifier) Swap(i, j int) { s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i] }
+func (s isoNodesByIdentifier) Less(i, j int) bool {
+	return bytes.Compare(s.nodes[i].identifier(s.tree), s.nodes[j].identifier(s.tree)) < 0
+}
+
+// Returns the name of the node as recorded in the tree. ISO9660 names only have upper case
+// letters, digits and underscores, with a single dot and a version in file names.
+func (node *isoNode) identifier(tree int) []byte {
+	if node.parent == node {
+		return []byte{0}
+	}
+	if tree == isoJolietTree {
+		return encodeUCS2(node.name)
+	}
+
+	name := []rune(strings.ToUpper(node.name))
+	dot := -1
+	for i, r := range name {
+		if r == '.' {
+			dot = i
+		}
+	}
+	for i, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || i == dot && !node.isDir) {
+			name[i] = '_'
+		}
+	}
+	if node.isDir {
+		return []byte(string(name))
+	}
+	if dot < 0 {
+		name = append(name, '.')
+	}
+	return []byte(string(name) + ";1")
+}
+
+// Size of the extent of a directory. Records do not cross sector boundaries.
+func (dir *isoNode) extentSize(tree int) uint32 {
+	size := uint32(0)
+	add := func(length uint32) {
+		if size%isoSectorSize+length > isoSectorSize {
+			size = isoSectors(size) * isoSectorSize
+		}
+		size += length
+	}
+	add(isoRecordLength(1))
+	add(isoRecordLength(1))
+	for _, child := range dir.sortedChildren(tree) {
+		add(isoRecordLength(len(child.identifier(tree))))
+	}
+	return isoSectors(size) * isoSectorSize
+}
+
+func (dir *isoNode) writeExtent(b []byte, tree int, modTime time.Time) {
+	offset := uint32(0)
+	write := func(node *isoNode, identifier []byte) {
+		length := isoRecordLength(len(identifier))
+		if offset%isoSectorSize+length > isoSectorSize {
+			offset = isoSectors(offset) * isoSectorSize
+		}
+		writeISORecord(b[offset:], node, tree, identifier, modTime)
+		offset += length
+	}
+	write(dir, []byte{0})
+	write(dir.parent, []byte{1})
+	for _, child := range dir.sortedChildren(tree) {
+		write(child, child.identifier(tree))
+	}
+}
+
+func isoRecordLength(identifierLength int) uint32 {
+	return uint32(33 + identifierLength + (identifierLength+1)%2)
+}
+
+func writeISORecord(b []byte, node *isoNode, tree int, identifier []byte, modTime time.Time) {
+	b[0] = byte(isoRecordLength(len(identifier)))
+	putBothEndian32(b[2:], node.sector[tree])
+	putBothEndian32(b[10:], node.size[tree])
+	putISORecordTime(b[18:], modTime)
+	if node.isDir {
+		b[25] = 2
+	}
+	putBothEndian16(b[28:], 1)
+	b[32] = byte(len(identifier))
+	copy(b[33:], identifier)
+}
+
+func isoPathTableSize(dirs []*isoNode, tree int) uint32 {
+	size := uint32(0)
+	for _, dir := range dirs {
+		length := uint32(len(dir.identifier(tree)))
+		size += 8 + length + length%2
+	}
+	return size
+}
+
+func writeISOPathTable(b []byte, dirs []*isoNode, tree int, order binary.ByteOrder) {
+	numbers := make(map[*isoNode]uint16)
+	offset := 0
+	for i, dir := range dirs {
+		numbers[dir] = uint16(i + 1)
+		identifier := dir.identifier(tree)
+		b[offset] = byte(len(identifier))
+		order.PutUint32(b[offset+2:], dir.sector[tree])
+		order.PutUint16(b[offset+6:], numbers[dir.parent])
+		copy(b[offset+8:], identifier)
+		offset += 8 + len(identifier) + len(identifier)%2
+	}
+}
+
+func writeISOVolumeDescriptor(b []byte, tree int, volumeID string, sectors uint32, root *isoNode, modTime time.Time) {
+	b[0] = 1
+	if tree == isoJolietTree {
+		b[0] = 2
+		// UCS-2 level 3
+		copy(b[88:], "%/E")
+	}
+	copy(b[1:], "CD001")
+	b[6] = 1
+	putISOText(b[8:40], "", tree)
+	putISOText(b[40:72], volumeID, tree)
+	putBothEndian32(b[80:], sectors)
+	putBothEndian16(b[120:], 1)
+	putBothEndian16(b[124:], 1)
+	putBothEndian16(b[128:], isoSectorSize)
+	writeISORecord(b[156:], root, tree, []byte{0}, modTime)
+	putISOText(b[190:318], "", tree)
+	putISOText(b[318:446], "", tree)
+	putISOText(b[446:574], "", tree)
+	putISOText(b[574:702], "PHOTON CLI", tree)
+	putISOText(b[702:813], "", tree)
+	putISOVolumeTime(b[813:], modTime)
+	putISOVolumeTime(b[830:], modTime)
+	putISOVolumeTime(b[847:], time.Time{})
+	putISOVolumeTime(b[864:], time.Time{})
+	b[881] = 1
+}
+
+// Fills a text field of a volume descriptor, padded with spaces
+func putISOText(b []byte, text string, tree int) {
+	var encoded []byte
+	if tree == isoJolietTree {
+		encoded = encodeUCS2(text)
+		for len(encoded)+2 <= len(b) {
+			encoded = append(encoded, 0, ' ')
+		}
+	} else {
+		encoded = []byte(text)
+		for len(encoded) < len(b) {
+			encoded = append(encoded, ' ')
+		}
+	}
+	copy(b, encoded)
+}
+
+func encodeUCS2(text string) []byte {
+	var encoded []byte
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = unicode.ReplacementChar
+		}
+		encoded = append(encoded, byte(r>>8), byte(r))
+	}
+	return encoded
+}
+
+func putISORecordTime(b []byte, t time.Time) {
+	t = t.UTC()
+	b[0] = byte(t.Year() - 1900)
+	b[1] = byte(t.Month())
+	b[2] = byte(t.Day())
+	b[3] = byte(t.Hour())
+	b[4] = byte(t.Minute())
+	b[5] = byte(t.Second())
+	b[6] = 0
+}
+
+// Volume descriptor dates are digits, all zeros when not specified
+func putISOVolumeTime(b []byte, t time.Time) {
+	if t.IsZero() {
+		copy(b, "0000000000000000")
+	} else {
+		t = t.UTC()
+		copy(b, fmt.Sprintf("%04d%02d%02d%02d%02d%02d00",
+			t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second()))
+	}
+	b[16] = 0
+}
+
+func putBothEndian16(b []byte, value uint16) {
+	binary.LittleEndian.PutUint16(b, value)
+	binary.BigEndian.PutUint16(b[2:], value)
+}
+
+func putBothEndian32(b []byte, value uint32) {
+	binary.LittleEndian.PutUint32(b, value)
+	binary.BigEndian.PutUint32(b[4:], value)
+}
+
+func isoSectors(size uint32) uint32 {
+	return (size + isoSectorSize - 1) / isoSectorSize
+}
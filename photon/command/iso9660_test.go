@@ -0,0 +1,108 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// Reads the volume label and the files of one of the trees of an ISO image
+func readISOTree(t *testing.T, image []byte, tree int) (string, map[string]string) {
+	descriptor := image[(16+tree)*isoSectorSize:]
+	if string(descriptor[1:6]) != "CD001" || int(descriptor[0]) != tree+1 {
+		t.Fatalf("Missing volume descriptor of tree %d", tree)
+	}
+	if binary.LittleEndian.Uint32(descriptor[80:]) != uint32(len(image)/isoSectorSize) {
+		t.Errorf("Volume size of tree %d does not match the image size", tree)
+	}
+	decode := func(b []byte) string {
+		if tree == isoPrimaryTree {
+			return string(b)
+		}
+		var chars []uint16
+		for i := 0; i+1 < len(b); i += 2 {
+			chars = append(chars, binary.BigEndian.Uint16(b[i:]))
+		}
+		return string(utf16.Decode(chars))
+	}
+
+	files := make(map[string]string)
+	var walk func(record []byte, path string)
+	walk = func(record []byte, path string) {
+		extent := image[binary.LittleEndian.Uint32(record[2:])*isoSectorSize:]
+		size := int(binary.LittleEndian.Uint32(record[10:]))
+		for offset := 0; offset < size; {
+			length := int(extent[offset])
+			if length == 0 {
+				offset = (offset/isoSectorSize + 1) * isoSectorSize
+				continue
+			}
+			child := extent[offset : offset+length]
+			offset += length
+			identifier := child[33 : 33+int(child[32])]
+			if len(identifier) == 1 && identifier[0] <= 1 {
+				continue
+			}
+			childPath := path + decode(identifier)
+			if child[25]&2 != 0 {
+				walk(child, childPath+"/")
+			} else {
+				start := binary.LittleEndian.Uint32(child[2:]) * isoSectorSize
+				files[childPath] = string(image[start : start+binary.LittleEndian.Uint32(child[10:])])
+			}
+		}
+	}
+	walk(descriptor[156:], "")
+	return strings.TrimRight(decode(descriptor[40:72]), " "), files
+}
+
+func TestWriteISO9660(t *testing.T) {
+	files := []isoFile{
+		{"user-data", []byte("#cloud-config\n")},
+		{"meta-data", []byte("instance-id: vm-1\n")},
+		{"openstack/latest/meta_data.json", []byte("{}")},
+		{"openstack/latest/empty", nil},
+		{"openstack/content/0000", []byte(strings.Repeat("x", 3*isoSectorSize+1))},
+	}
+	var buf bytes.Buffer
+	err := writeISO9660(&buf, "cidata", files, time.Now())
+	if err != nil {
+		t.Fatal("Not expecting writing the ISO image to fail: ", err)
+	}
+	if buf.Len()%isoSectorSize != 0 {
+		t.Errorf("Image size %d is not a multiple of the sector size", buf.Len())
+	}
+
+	expected := make(map[string]string)
+	for _, file := range files {
+		expected[file.Path] = string(file.Data)
+	}
+	label, joliet := readISOTree(t, buf.Bytes(), isoJolietTree)
+	if label != "cidata" || !reflect.DeepEqual(joliet, expected) {
+		t.Errorf("Unexpected Joliet tree '%s': %v", label, joliet)
+	}
+
+	label, primary := readISOTree(t, buf.Bytes(), isoPrimaryTree)
+	if label != "cidata" || primary["USER_DATA.;1"] != "#cloud-config\n" ||
+		primary["OPENSTACK/LATEST/META_DATA.JSON;1"] != "{}" || len(primary) != len(files) {
+		t.Errorf("Unexpected primary tree '%s': %v", label, primary)
+	}
+
+	err = writeISO9660(&buf, "cidata", []isoFile{{"a/b", nil}, {"a/b/c", nil}}, time.Now())
+	if err == nil {
+		t.Error("Expected a file used as a directory to fail")
+	}
+}
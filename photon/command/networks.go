@@ -187,6 +187,11 @@ func deleteNetwork(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveNetworkID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.Networks.Delete(id)
 	if err != nil {
 		return err
@@ -259,6 +264,11 @@ func showNetwork(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveNetworkID(id)
+	if err != nil {
+		return err
+	}
+
 	network, err := client.Esxclient.Networks.Get(id)
 	if err != nil {
 		return err
@@ -294,6 +304,11 @@ func setDefaultNetwork(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	id, err = resolveNetworkID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.Networks.SetDefault(id)
 	if err != nil {
 		return err
@@ -61,6 +61,7 @@ func TestCreateDeleteNetwork(t *testing.T) {
 		mocks.CreateResponder(200, string(taskresponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/networks", "network-ID")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -228,6 +229,7 @@ func TestShowNetworks(t *testing.T) {
 		mocks.CreateResponder(200, string(response[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/networks", "network_id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -264,6 +266,7 @@ func TestSetDefaultNetwork(t *testing.T) {
 		mocks.CreateResponder(200, string(taskresponse[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/networks", "id")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
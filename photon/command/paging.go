@@ -0,0 +1,206 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// A single page of a list. The items are left undecoded so that callers can decode
+// them into the item type of the list.
+type listPage struct {
+	Items            []json.RawMessage `json:"items"`
+	NextPageLink     string            `json:"nextPageLink"`
+	PreviousPageLink string            `json:"previousPageLink"`
+}
+
+// How a list command pages through a list
+type listPaging struct {
+	limit     int
+	pageSize  int
+	pageToken string
+}
+
+// Flags of the list commands that stream their results page by page
+func pagingFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.IntFlag{
+			Name:  "limit",
+			Usage: "Maximum number of items to fetch, filters applied by the CLI only see the fetched items and may list fewer",
+		},
+		cli.IntFlag{
+			Name:  "page-size",
+			Usage: "Number of items fetched per request, the server default is used if not set",
+		},
+		cli.StringFlag{
+			Name:  "page-token",
+			Usage: "Continue a previous list from the page token it printed",
+		},
+	}
+}
+
+func getListPaging(c *cli.Context) (*listPaging, error) {
+	paging := &listPaging{
+		limit:     c.Int("limit"),
+		pageSize:  c.Int("page-size"),
+		pageToken: c.String("page-token"),
+	}
+	if paging.limit < 0 || paging.pageSize < 0 {
+		return nil, errors.New("--limit and --page-size cannot be negative")
+	}
+	if paging.limit > 0 && paging.pageSize > 0 && paging.limit%paging.pageSize != 0 {
+		return nil, errors.New("--limit must be a multiple of --page-size, so that the list can be continued")
+	}
+	if len(paging.pageToken) != 0 && !strings.HasPrefix(paging.pageToken, "/") {
+		return nil, fmt.Errorf("Invalid page token '%s'", paging.pageToken)
+	}
+	if paging.pageSize == 0 {
+		paging.pageSize = paging.limit
+	}
+	return paging, nil
+}
+
+// Fetches a list one page at a time and hands the items of every page to handlePage, until the
+// list or the limit is exhausted. Only one page is held in memory at a time.
+// Returns the token of the next page, which is empty when there is nothing left to list, or when
+// the limit ended in the middle of a page since such a list cannot be continued.
+func streamList(uri string, query url.Values, paging *listPaging, handlePage func([]json.RawMessage) error) (string, error) {
+	if len(paging.pageToken) != 0 {
+		uri = paging.pageToken
+	} else {
+		if paging.pageSize > 0 {
+			query.Set("pageSize", strconv.Itoa(paging.pageSize))
+		}
+		if len(query) != 0 {
+			uri += "?" + query.Encode()
+		}
+	}
+
+	// All the pages are fetched over the same connection
+	config, err := cf.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	httpClient, err := getPageHTTPClient(client.Esxclient.Endpoint, config)
+	if err != nil {
+		return "", err
+	}
+	if transport, ok := httpClient.Transport.(*http.Transport); ok {
+		defer transport.CloseIdleConnections()
+	}
+
+	count := 0
+	for len(uri) != 0 {
+		page, err := getListPage(uri, config.Token, httpClient)
+		if err != nil {
+			return "", err
+		}
+		items := page.Items
+		uri = page.NextPageLink
+		if paging.limit > 0 && count+len(items) >= paging.limit {
+			if count+len(items) > paging.limit {
+				items = items[:paging.limit-count]
+				uri = ""
+			}
+			return uri, handlePage(items)
+		}
+		count += len(items)
+		err = handlePage(items)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Gets a single page of a list. The SDK only has GetAll calls, which fetch every page before
+// returning, so the request is made here with the token of the configuration.
+// The uri is relative to the endpoint: a list path with its query string, or the NextPageLink
+// of a previous page.
+func getListPage(uri string, token string, httpClient *http.Client) (*listPage, error) {
+	req, err := http.NewRequest("GET", client.Esxclient.Endpoint+uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) != 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Errors are reported like the SDK does
+	if res.StatusCode/100 != 2 {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		var apiError photon.ApiError
+		err = json.Unmarshal(body, &apiError)
+		if err != nil {
+			return nil, photon.HttpError{StatusCode: res.StatusCode, Message: string(body)}
+		}
+		apiError.HttpStatusCode = res.StatusCode
+		return nil, apiError
+	}
+
+	page := &listPage{}
+	err = json.NewDecoder(res.Body).Decode(page)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Verifies the certificate of an https endpoint the same way the SDK client does
+func getPageHTTPClient(endpoint string, config *cf.Configuration) (*http.Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" {
+		return http.DefaultClient, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.IgnoreCertificate}
+	if !config.IgnoreCertificate {
+		tlsConfig.RootCAs, err = cf.GetCertsFromLocalStore()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
+
+// Tells the user how to continue a list. Scripts get the token on stderr to keep their output parsable.
+func printNextPageToken(token string, w io.Writer, c *cli.Context) {
+	if len(token) == 0 {
+		return
+	}
+	if c.GlobalIsSet("non-interactive") || utils.NeedsFormatting(c) {
+		fmt.Fprintf(os.Stderr, "Next page token: %s\n", token)
+	} else {
+		fmt.Fprintf(w, "More items are available, use --page-token %s to continue\n", token)
+	}
+}
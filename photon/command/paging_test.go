@@ -0,0 +1,168 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestStreamList(t *testing.T) {
+	pages := []MockTasksPage{
+		{
+			Items:        []photon.Task{{ID: "task-1"}, {ID: "task-2"}},
+			NextPageLink: "/tasks?pageLink=page-2",
+		},
+		{
+			Items:        []photon.Task{{ID: "task-3"}, {ID: "task-4"}},
+			NextPageLink: "/tasks?pageLink=page-3",
+		},
+		{
+			Items: []photon.Task{{ID: "task-5"}},
+		},
+	}
+	urls := []string{"/tasks?pageSize=2&state=COMPLETED", "/tasks?pageLink=page-2", "/tasks?pageLink=page-3"}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	for i, page := range pages {
+		response, err := json.Marshal(page)
+		if err != nil {
+			t.Error("Not expecting error serializing tasks")
+		}
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+urls[i],
+			mocks.CreateResponder(200, string(response[:])))
+	}
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	var tests = []struct {
+		Paging    listPaging
+		PageSizes []int
+		Token     string
+	}{
+		{listPaging{pageSize: 2}, []int{2, 2, 1}, ""},
+		{listPaging{limit: 4, pageSize: 2}, []int{2, 2}, "/tasks?pageLink=page-3"},
+		{listPaging{limit: 3, pageSize: 2}, []int{2, 1}, ""},
+		{listPaging{pageToken: "/tasks?pageLink=page-2"}, []int{2, 1}, ""},
+	}
+
+	for _, test := range tests {
+		var pageSizes []int
+		query := url.Values{"state": []string{"COMPLETED"}}
+		token, err := streamList("/tasks", query, &test.Paging, func(items []json.RawMessage) error {
+			pageSizes = append(pageSizes, len(items))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Not expecting streaming %+v to fail: %s", test.Paging, err)
+		}
+		if token != test.Token {
+			t.Errorf("Streaming %+v returned token '%s', expected '%s'", test.Paging, token, test.Token)
+		}
+		if len(pageSizes) != len(test.PageSizes) {
+			t.Errorf("Streaming %+v returned pages of %v items, expected %v", test.Paging, pageSizes, test.PageSizes)
+			continue
+		}
+		for i := range pageSizes {
+			if pageSizes[i] != test.PageSizes[i] {
+				t.Errorf("Streaming %+v returned pages of %v items, expected %v", test.Paging, pageSizes, test.PageSizes)
+			}
+		}
+	}
+}
+
+func TestListWriter(t *testing.T) {
+	tasks := []photon.Task{
+		{ID: "task-1", State: "COMPLETED", Operation: "CREATE_VM"},
+		{ID: "task-2", State: "ERROR", Operation: "DELETE_VM"},
+		{ID: "task-3", State: "QUEUED", Operation: "START_VM"},
+	}
+
+	for _, output := range []string{"json", "csv"} {
+		globalSet := flag.NewFlagSet("global", 0)
+		globalSet.String("output", output, "output")
+		err := globalSet.Parse([]string{"--output", output})
+		if err != nil {
+			t.Error("Not expecting global arguments parsing to fail")
+		}
+		cxt := cli.NewContext(nil, globalSet, nil)
+
+		for _, list := range [][]photon.Task{tasks, nil} {
+			var expected bytes.Buffer
+			utils.FormatObjects(list, &expected, cxt)
+
+			var streamed bytes.Buffer
+			writer := utils.NewListWriter(&streamed, cxt)
+			if len(list) != 0 {
+				err = writer.Write(list[:2])
+				if err != nil {
+					t.Error("Not expecting writing the first page to fail: ", err)
+				}
+				err = writer.Write(list[2:])
+				if err != nil {
+					t.Error("Not expecting writing the second page to fail: ", err)
+				}
+			}
+			err = writer.Close()
+			if err != nil {
+				t.Error("Not expecting closing the list to fail: ", err)
+			}
+
+			if streamed.String() != expected.String() {
+				t.Errorf("Streamed %s output differs, expected:\n%s\ngot:\n%s", output, expected.String(), streamed.String())
+			}
+		}
+	}
+}
+
+func TestVMListPrinterStreamsPages(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	cxt := cli.NewContext(nil, set, nil)
+
+	var buf bytes.Buffer
+	printer := newVMListPrinter(&buf, cxt, false, false, false)
+	err := printer.printPage([]vmListItem{{VM: photon.VM{ID: "vm-1", Name: "a", State: "STARTED"}}})
+	if err != nil {
+		t.Error("Not expecting printing a page to fail: ", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 || strings.Index(lines[1], "STARTED") != strings.Index(lines[0], "State") {
+		t.Errorf("Expected the first page to be printed before the next one is fetched, got:\n%s", buf.String())
+	}
+
+	err = printer.printPage([]vmListItem{{VM: photon.VM{ID: "vm-2", Name: "b", State: "STOPPED"}}})
+	if err != nil {
+		t.Error("Not expecting printing a page to fail: ", err)
+	}
+	err = printer.done()
+	if err != nil {
+		t.Error("Not expecting finishing the list to fail: ", err)
+	}
+	if !strings.Contains(buf.String(), "vm-2") || !strings.Contains(buf.String(), "Total: 2") {
+		t.Errorf("Unexpected list output:\n%s", buf.String())
+	}
+}
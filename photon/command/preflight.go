@@ -0,0 +1,279 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/photon/manifest"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+const (
+	preflightPass = "PASS"
+	preflightWarn = "WARN"
+	preflightFail = "FAIL"
+)
+
+// Result of a single pre-flight check
+type preflightCheck struct {
+	Check   string `json:"check"`
+	Target  string `json:"target"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	preflightTimeout     = 5 * time.Second
+	preflightParallelism = 20
+
+	ntpPort    = "123"
+	syslogPort = "514"
+)
+
+// Port the ESX hosts are probed on, changed by tests
+var esxPort = "443"
+
+// Run the pre-flight checks for a DC map and print the results
+func preflight(c *cli.Context) error {
+	err := checkArgNum(c.Args(), 1, "system preflight <file>")
+	if err != nil {
+		return err
+	}
+	dcMap, err := manifest.LoadInstallation(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return runPreflight(dcMap, c)
+}
+
+// Run the pre-flight checks, print the results and return an error if any check failed
+func runPreflight(dcMap *manifest.Installation, c *cli.Context) error {
+	checks, err := getPreflightChecks(dcMap, utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+
+	err = printPreflightChecks(checks, c)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if check.Result == preflightFail {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d pre-flight checks failed", failed)
+	}
+	return nil
+}
+
+func getPreflightChecks(dcMap *manifest.Installation, isScripting bool) ([]preflightCheck, error) {
+	var checks []preflightCheck
+
+	// Host addresses must be unique and not be used by management VMs
+	var hostIps []string
+	hostIpSet := make(map[string]bool)
+	for _, host := range dcMap.Hosts {
+		ips, err := parseIpRanges(host.IpRanges)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if hostIpSet[ip] {
+				checks = append(checks, preflightCheck{"host address", ip, preflightFail,
+					"address is listed more than once"})
+				continue
+			}
+			hostIpSet[ip] = true
+			hostIps = append(hostIps, ip)
+		}
+	}
+
+	for _, host := range dcMap.Hosts {
+		managementVmIps, exists := host.Metadata["MANAGEMENT_VM_IPS"]
+		if !exists {
+			continue
+		}
+		ips, err := parseIpRanges(managementVmIps)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if hostIpSet[ip] {
+				checks = append(checks, preflightCheck{"management VM IP", ip, preflightFail,
+					"address is also used by a host"})
+			} else {
+				checks = append(checks, preflightCheck{"management VM IP", ip, preflightPass, ""})
+			}
+		}
+	}
+
+	hostChecks := make([]preflightCheck, len(hostIps))
+	runInParallel(len(hostIps), preflightParallelism, "PREFLIGHT", isScripting, func(i int) error {
+		hostChecks[i] = checkTCPEndpoint("host reachability", net.JoinHostPort(hostIps[i], esxPort))
+		if hostChecks[i].Result == preflightFail {
+			return errors.New(hostChecks[i].Message)
+		}
+		return nil
+	})
+	checks = append(checks, hostChecks...)
+
+	for _, endpoint := range getEndpointList(dcMap.Deployment.NTPEndpoint) {
+		checks = append(checks, checkNTPEndpoint(endpoint))
+	}
+	for _, endpoint := range getEndpointList(dcMap.Deployment.SyslogEndpoint) {
+		checks = append(checks, checkSyslogEndpoint(endpoint))
+	}
+
+	if dcMap.Deployment.StatsEnabled && len(dcMap.Deployment.StatsStoreEndpoint) != 0 {
+		address := net.JoinHostPort(dcMap.Deployment.StatsStoreEndpoint, strconv.Itoa(dcMap.Deployment.StatsPort))
+		checks = append(checks, checkTCPEndpoint("stats endpoint", address))
+	}
+
+	// Lightwave is set up by the deployment itself, the DC map has no endpoint to check yet
+	if dcMap.Deployment.AuthEnabled {
+		checks = append(checks, preflightCheck{"Lightwave endpoint", "-", preflightWarn,
+			"the DC map has no Lightwave endpoint, it cannot be checked before the deployment"})
+	}
+
+	return checks, nil
+}
+
+// NTP and syslog endpoints are either a comma separated string or a list
+func getEndpointList(value interface{}) []string {
+	var endpoints []string
+	switch v := value.(type) {
+	case string:
+		for _, endpoint := range regexp.MustCompile(`\s*,\s*`).Split(v, -1) {
+			if len(endpoint) != 0 {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	case []interface{}:
+		for _, endpoint := range v {
+			endpoints = append(endpoints, fmt.Sprint(endpoint))
+		}
+	}
+	return endpoints
+}
+
+// Adds the default port to an endpoint that does not specify one
+func withDefaultPort(endpoint string, port string) string {
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return endpoint
+	}
+	return net.JoinHostPort(endpoint, port)
+}
+
+// Checks that the host part of an address resolves, returns a failed check if not
+func checkResolves(check string, address string) *preflightCheck {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return &preflightCheck{check, address, preflightFail, err.Error()}
+	}
+	_, err = net.LookupHost(host)
+	if err != nil {
+		return &preflightCheck{check, address, preflightFail, "cannot resolve: " + err.Error()}
+	}
+	return nil
+}
+
+func checkTCPEndpoint(check string, address string) preflightCheck {
+	if failed := checkResolves(check, address); failed != nil {
+		return *failed
+	}
+	conn, err := net.DialTimeout("tcp", address, preflightTimeout)
+	if err != nil {
+		return preflightCheck{check, address, preflightFail, err.Error()}
+	}
+	_ = conn.Close()
+	return preflightCheck{check, address, preflightPass, ""}
+}
+
+// Sends an SNTP request and waits for the answer
+func checkNTPEndpoint(endpoint string) preflightCheck {
+	address := withDefaultPort(endpoint, ntpPort)
+	if failed := checkResolves("NTP endpoint", address); failed != nil {
+		return *failed
+	}
+	conn, err := net.DialTimeout("udp", address, preflightTimeout)
+	if err != nil {
+		return preflightCheck{"NTP endpoint", address, preflightFail, err.Error()}
+	}
+	defer conn.Close()
+
+	request := make([]byte, 48)
+	request[0] = 0x1B // version 3, client mode
+	err = conn.SetDeadline(time.Now().Add(preflightTimeout))
+	if err == nil {
+		_, err = conn.Write(request)
+	}
+	if err == nil {
+		_, err = conn.Read(request)
+	}
+	if err != nil {
+		return preflightCheck{"NTP endpoint", address, preflightFail, "no answer from NTP server: " + err.Error()}
+	}
+	return preflightCheck{"NTP endpoint", address, preflightPass, ""}
+}
+
+// Syslog usually runs over UDP which cannot be probed, so only a missing TCP listener is a warning
+func checkSyslogEndpoint(endpoint string) preflightCheck {
+	address := withDefaultPort(endpoint, syslogPort)
+	if failed := checkResolves("syslog endpoint", address); failed != nil {
+		return *failed
+	}
+	conn, err := net.DialTimeout("tcp", address, preflightTimeout)
+	if err != nil {
+		return preflightCheck{"syslog endpoint", address, preflightWarn,
+			"no TCP listener, UDP syslog cannot be verified"}
+	}
+	_ = conn.Close()
+	return preflightCheck{"syslog endpoint", address, preflightPass, ""}
+}
+
+func printPreflightChecks(checks []preflightCheck, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, check := range checks {
+			fmt.Printf("%s\t%s\t%s\t%s\n", check.Check, check.Target, check.Result, check.Message)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(checks, os.Stdout, c)
+	} else {
+		resultCount := make(map[string]int)
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Check\tTarget\tResult\tMessage\n")
+		for _, check := range checks {
+			resultCount[check.Result]++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", check.Check, check.Target, check.Result, valueOrDash(check.Message))
+		}
+		err := w.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nPassed: %d, Warnings: %d, Failed: %d\n",
+			resultCount[preflightPass], resultCount[preflightWarn], resultCount[preflightFail])
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/manifest"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+)
+
+func TestPreflight(t *testing.T) {
+	// ESX hosts and the stats store are answered by a local TCP listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Not expecting error listening on local port")
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, tcpPort, _ := net.SplitHostPort(listener.Addr().String())
+
+	// NTP is answered by a local UDP responder
+	ntpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Not expecting error listening on local UDP port")
+	}
+	defer ntpConn.Close()
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := ntpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = ntpConn.WriteTo(buf, addr)
+		}
+	}()
+
+	originalEsxPort := esxPort
+	esxPort = tcpPort
+	defer func() {
+		esxPort = originalEsxPort
+	}()
+
+	dcmap := fmt.Sprintf(`---
+deployment:
+  ntp_endpoint: %s
+  syslog_endpoint: 127.0.0.1:1
+  stats_enabled: true
+  stats_store_endpoint: 127.0.0.1
+  stats_port: %s
+  auth_enabled: true
+hosts:
+  - address_ranges: 127.0.0.1
+    metadata:
+      MANAGEMENT_VM_IPS: 127.0.0.1
+`, ntpConn.LocalAddr().String(), tcpPort)
+
+	f, err := ioutil.TempFile("", "tempDcMap")
+	if err != nil {
+		t.Error("Fail to create temperory Dc_Map")
+	}
+	defer func() {
+		err = syscall.Unlink(f.Name())
+		if err != nil {
+			t.Error("Failed to unlink test dc_map file.")
+		}
+	}()
+	err = ioutil.WriteFile(f.Name(), []byte(dcmap), 0644)
+	if err != nil {
+		t.Error("Failed to create test dc_map file.")
+	}
+
+	dcMap, err := manifest.LoadInstallation(f.Name())
+	if err != nil {
+		t.Fatal("Not expecting error loading DC map: ", err)
+	}
+
+	checks, err := getPreflightChecks(dcMap, true)
+	if err != nil {
+		t.Fatal("Not expecting error running pre-flight checks: ", err)
+	}
+
+	expected := map[string]string{
+		"management VM IP":   preflightFail,
+		"host reachability":  preflightPass,
+		"NTP endpoint":       preflightPass,
+		"syslog endpoint":    preflightWarn,
+		"stats endpoint":     preflightPass,
+		"Lightwave endpoint": preflightWarn,
+	}
+	if len(checks) != len(expected) {
+		t.Errorf("Expected %d checks, got %d: %v", len(expected), len(checks), checks)
+	}
+	for _, check := range checks {
+		if expected[check.Check] != check.Result {
+			t.Errorf("Expected check '%s' to be %s, got %s (%s)",
+				check.Check, expected[check.Check], check.Result, check.Message)
+		}
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	err = preflight(cxt)
+	if err == nil || err.Error() != "1 pre-flight checks failed" {
+		t.Errorf("Expected pre-flight to report the management VM IP collision, got '%v'", err)
+	}
+}
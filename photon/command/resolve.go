@@ -0,0 +1,248 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+)
+
+// Entity IDs are UUIDs, arguments in this format are used as they are without a lookup
+var fullIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}$`)
+
+// An entity an argument can be resolved to
+type resolveCandidate struct {
+	ID   string
+	Name string
+	Kind string
+}
+
+// Sorts the candidates listed for an ambiguous argument by ID
+type candidatesSorter []resolveCandidate
+
+func (c candidatesSorter) Len() int           { return len(c) }
+func (c candidatesSorter) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c candidatesSorter) Less(i, j int) bool { return c[i].ID < c[j].ID }
+
+// Resolves a name, an ID or an unambiguous ID prefix to the ID of one of the candidates.
+// An exact ID wins over a name, which wins over an ID prefix.
+func resolveCandidates(kind string, nameOrID string, candidates []resolveCandidate) (string, error) {
+	if len(nameOrID) == 0 {
+		return "", fmt.Errorf("Please provide the %s name or ID", kind)
+	}
+	var byName, byPrefix []resolveCandidate
+	for _, candidate := range candidates {
+		if candidate.ID == nameOrID {
+			return candidate.ID, nil
+		}
+		if candidate.Name == nameOrID {
+			byName = append(byName, candidate)
+		}
+		if strings.HasPrefix(candidate.ID, nameOrID) {
+			byPrefix = append(byPrefix, candidate)
+		}
+	}
+
+	matches := byName
+	if len(matches) == 0 {
+		matches = byPrefix
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("No %s matches '%s'", kind, nameOrID)
+	case 1:
+		return matches[0].ID, nil
+	}
+
+	sort.Sort(candidatesSorter(matches))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "'%s' matches %d %ss, please use one of the IDs:\n", nameOrID, len(matches), kind)
+	w := new(tabwriter.Writer)
+	w.Init(&buf, 4, 4, 2, ' ', 0)
+	for _, match := range matches {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", match.ID, match.Name, match.Kind)
+	}
+	err := w.Flush()
+	if err != nil {
+		return "", err
+	}
+	return "", errors.New(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// Resolves a VM of the current project. Without a current project the argument is used as an ID.
+func resolveVMID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	config, err := cf.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if config.Project == nil {
+		return nameOrID, nil
+	}
+	return resolveProjectVMID(config.Project.ID, nameOrID)
+}
+
+// Resolves a VM of the given project
+func resolveProjectVMID(projectID string, nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	vms, err := client.Esxclient.Projects.GetVMs(projectID, nil)
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, vm := range vms.Items {
+		candidates = append(candidates, resolveCandidate{ID: vm.ID, Name: vm.Name})
+	}
+	return resolveCandidates("VM", nameOrID, candidates)
+}
+
+// Resolves a persistent disk of the current project. Without a current project the argument is used as an ID.
+func resolveDiskID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	config, err := cf.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if config.Project == nil {
+		return nameOrID, nil
+	}
+	return resolveProjectDiskID(config.Project.ID, nameOrID)
+}
+
+// Resolves a disk of the given project
+func resolveProjectDiskID(projectID string, nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	disks, err := client.Esxclient.Projects.GetDisks(projectID, nil)
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, disk := range disks.Items {
+		candidates = append(candidates, resolveCandidate{ID: disk.ID, Name: disk.Name})
+	}
+	return resolveCandidates("disk", nameOrID, candidates)
+}
+
+// Resolves a cluster of the current project. Without a current project the argument is used as an ID.
+func resolveClusterID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	config, err := cf.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if config.Project == nil {
+		return nameOrID, nil
+	}
+	clusters, err := client.Esxclient.Projects.GetClusters(config.Project.ID)
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, cluster := range clusters.Items {
+		candidates = append(candidates, resolveCandidate{ID: cluster.ID, Name: cluster.Name})
+	}
+	return resolveCandidates("cluster", nameOrID, candidates)
+}
+
+func resolveImageID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	images, err := client.Esxclient.Images.GetAll(nil)
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, image := range images.Items {
+		candidates = append(candidates, resolveCandidate{ID: image.ID, Name: image.Name})
+	}
+	return resolveCandidates("image", nameOrID, candidates)
+}
+
+// Resolves a flavor, names are only unique for a kind so an empty kind matches the flavors of every kind
+func resolveFlavorID(nameOrID string, kind string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	flavors, err := client.Esxclient.Flavors.GetAll(&photon.FlavorGetOptions{Kind: kind})
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, flavor := range flavors.Items {
+		candidates = append(candidates, resolveCandidate{ID: flavor.ID, Name: flavor.Name, Kind: flavor.Kind})
+	}
+	return resolveCandidates("flavor", nameOrID, candidates)
+}
+
+func resolveNetworkID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	networks, err := client.Esxclient.Networks.GetAll(nil)
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, network := range networks.Items {
+		candidates = append(candidates, resolveCandidate{ID: network.ID, Name: network.Name})
+	}
+	return resolveCandidates("network", nameOrID, candidates)
+}
+
+// Resolves a host by its address or ID
+func resolveHostID(addressOrID string) (string, error) {
+	if fullIDPattern.MatchString(addressOrID) {
+		return addressOrID, nil
+	}
+	hosts, err := client.Esxclient.Hosts.GetAll()
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, host := range hosts.Items {
+		candidates = append(candidates, resolveCandidate{ID: host.ID, Name: host.Address})
+	}
+	return resolveCandidates("host", addressOrID, candidates)
+}
+
+func resolveAvailabilityZoneID(nameOrID string) (string, error) {
+	if fullIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return "", err
+	}
+	var candidates []resolveCandidate
+	for _, zone := range zones.Items {
+		candidates = append(candidates, resolveCandidate{ID: zone.ID, Name: zone.Name})
+	}
+	return resolveCandidates("availability zone", nameOrID, candidates)
+}
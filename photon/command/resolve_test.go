@@ -0,0 +1,135 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Registers a list of entities with the given IDs, the resolver looks up every argument that is not a UUID
+func registerResolveList(url string, ids ...string) {
+	var items []string
+	for _, id := range ids {
+		items = append(items, fmt.Sprintf(`{"id":"%s"}`, id))
+	}
+	mocks.RegisterResponder(
+		"GET",
+		url,
+		mocks.CreateResponder(200, `{"items":[`+strings.Join(items, ",")+`]}`))
+}
+
+func TestResolveCandidates(t *testing.T) {
+	candidates := []resolveCandidate{
+		{ID: "a1b2-0001", Name: "web"},
+		{ID: "a1b2-0002", Name: "web"},
+		{ID: "c3d4-0003", Name: "db"},
+		{ID: "e5f6-0004", Name: "a1b2-0001"},
+	}
+
+	var tests = []struct {
+		NameOrID  string
+		ID        string
+		Ambiguous bool
+	}{
+		{"c3d4-0003", "c3d4-0003", false},
+		{"a1b2-0001", "a1b2-0001", false},
+		{"db", "c3d4-0003", false},
+		{"c3", "c3d4-0003", false},
+		{"web", "", true},
+		{"a1b2", "", true},
+		{"missing", "", false},
+	}
+
+	for _, test := range tests {
+		id, err := resolveCandidates("VM", test.NameOrID, candidates)
+		if id != test.ID {
+			t.Errorf("Resolving '%s' returned '%s', expected '%s'", test.NameOrID, id, test.ID)
+		}
+		if len(test.ID) == 0 && err == nil {
+			t.Errorf("Expected resolving '%s' to fail", test.NameOrID)
+		}
+		if test.Ambiguous && (err == nil || !strings.Contains(err.Error(), "a1b2-0002")) {
+			t.Errorf("Expected the candidates for '%s' to be listed, got: %v", test.NameOrID, err)
+		}
+	}
+}
+
+func TestResolveFlavorID(t *testing.T) {
+	flavors := `{"items":[
+		{"id":"flavor-1","name":"small","kind":"vm"},
+		{"id":"flavor-2","name":"small","kind":"ephemeral-disk"}]}`
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/flavors",
+		mocks.CreateResponder(200, flavors))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/flavors?kind=vm",
+		mocks.CreateResponder(200, `{"items":[{"id":"flavor-1","name":"small","kind":"vm"}]}`))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	id, err := resolveFlavorID("small", "vm")
+	if err != nil || id != "flavor-1" {
+		t.Errorf("Expected 'small' to resolve to flavor-1, got '%s', %v", id, err)
+	}
+	_, err = resolveFlavorID("small", "")
+	if err == nil || !strings.Contains(err.Error(), "ephemeral-disk") {
+		t.Errorf("Expected 'small' to be ambiguous without a kind, got: %v", err)
+	}
+	id, err = resolveFlavorID("7f1e0a4c-2b9d-4e8a-9c3f-5d6b7a8e9f01", "")
+	if err != nil || id != "7f1e0a4c-2b9d-4e8a-9c3f-5d6b7a8e9f01" {
+		t.Errorf("Expected a UUID to be used as it is, got '%s', %v", id, err)
+	}
+}
+
+func TestResolveAvailabilityZoneID(t *testing.T) {
+	zones := `{"items":[
+		{"id":"zone-a1","name":"rack"},
+		{"id":"zone-a2","name":"rack"},
+		{"id":"zone-b1","name":"edge"}]}`
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, zones))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	id, err := resolveAvailabilityZoneID("edge")
+	if err != nil || id != "zone-b1" {
+		t.Errorf("Expected 'edge' to resolve to zone-b1, got '%s', %v", id, err)
+	}
+	id, err = resolveAvailabilityZoneID("zone-b")
+	if err != nil || id != "zone-b1" {
+		t.Errorf("Expected the ID prefix 'zone-b' to resolve to zone-b1, got '%s', %v", id, err)
+	}
+	_, err = resolveAvailabilityZoneID("rack")
+	if err == nil || !strings.Contains(err.Error(), "zone-a1") || !strings.Contains(err.Error(), "zone-a2") {
+		t.Errorf("Expected 'rack' to be ambiguous and list both zones, got: %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/manifest"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+const (
+	syncAdd    = "add"
+	syncMove   = "move"
+	syncDrift  = "drift"
+	syncRemove = "remove"
+	syncKeep   = "keep"
+)
+
+// Difference between a DC map host and the host registered in the deployment
+type syncChange struct {
+	Action  string `json:"action"`
+	Address string `json:"address"`
+	HostID  string `json:"hostId,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+
+	spec *photon.HostCreateSpec
+	host *photon.Host
+}
+
+// Reconcile the hosts of a deployment with a DC map
+func syncHosts(c *cli.Context) error {
+	err := checkArgNum(c.Args(), 1, "system sync <file> [<options>]")
+	if err != nil {
+		return err
+	}
+	dcMap, err := manifest.LoadInstallation(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+
+	deploymentID, err := findDeploymentID(c.String("deployment"))
+	if err != nil {
+		return err
+	}
+
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return err
+	}
+	availabilityZoneNameToIdMap := make(map[string]string)
+	availabilityZoneNames := make(map[string]string)
+	for _, zone := range zones.Items {
+		availabilityZoneNameToIdMap[zone.Name] = zone.ID
+		availabilityZoneNames[zone.ID] = zone.Name
+	}
+
+	dryRun := c.Bool("dry-run")
+	if dryRun {
+		// Zones that would be created are shown by name
+		for _, host := range dcMap.Hosts {
+			if _, present := availabilityZoneNameToIdMap[host.AvailabilityZone]; !present && len(host.AvailabilityZone) > 0 {
+				availabilityZoneNameToIdMap[host.AvailabilityZone] = host.AvailabilityZone
+				availabilityZoneNames[host.AvailabilityZone] = host.AvailabilityZone
+			}
+		}
+	} else {
+		err = createAvailabilityZonesFromDcMap(dcMap, availabilityZoneNameToIdMap, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	hostSpecs, err := createHostSpecs(dcMap, availabilityZoneNameToIdMap)
+	if err != nil {
+		return err
+	}
+	hosts, err := client.Esxclient.Deployments.GetHosts(deploymentID)
+	if err != nil {
+		return err
+	}
+
+	changes := getSyncChanges(hostSpecs, hosts.Items, availabilityZoneNames, c.Bool("prune"))
+	err = printSyncChanges(changes, c)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+
+	var addSpecs []photon.HostCreateSpec
+	var removals []syncChange
+	for _, change := range changes {
+		if change.Action == syncAdd {
+			addSpecs = append(addSpecs, *change.spec)
+		}
+		if change.Action == syncRemove && c.Bool("prune") {
+			removals = append(removals, change)
+		}
+	}
+	if len(removals) != 0 {
+		fmt.Printf("%d hosts will be deleted.\n", len(removals))
+		if !confirmed(utils.IsNonInteractive(c)) {
+			fmt.Println("OK. Canceled")
+			return nil
+		}
+	}
+
+	failed := 0
+	if len(addSpecs) != 0 {
+		_, err = registerHosts(addSpecs, deploymentID, nil, c, os.Stdout)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			failed++
+		}
+	}
+
+	for _, change := range changes {
+		if change.Action != syncMove {
+			continue
+		}
+		err = moveHost(change.HostID, change.spec.AvailabilityZone)
+		if err != nil {
+			fmt.Printf("Moving host with ip '%s' failed: %s\n", change.Address, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Host with ip '%s' moved to availability zone %s\n", change.Address, change.spec.AvailabilityZone)
+	}
+
+	for _, change := range removals {
+		err = pruneHost(change.host)
+		if err != nil {
+			fmt.Printf("Deleting host with ip '%s' failed: %s\n", change.Address, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Host with ip '%s' deleted: ID = %s\n", change.Address, change.HostID)
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d sync operations failed", failed)
+	}
+	return nil
+}
+
+// Compares the hosts described by the DC map with the hosts of the deployment
+func getSyncChanges(hostSpecs []photon.HostCreateSpec, hosts []photon.Host,
+	availabilityZoneNames map[string]string, prune bool) []syncChange {
+	var changes []syncChange
+
+	existing := make(map[string]*photon.Host)
+	for i := range hosts {
+		existing[hosts[i].Address] = &hosts[i]
+	}
+
+	desired := make(map[string]bool)
+	for i := range hostSpecs {
+		spec := &hostSpecs[i]
+		desired[spec.Address] = true
+		host, present := existing[spec.Address]
+		if !present {
+			changes = append(changes, syncChange{Action: syncAdd, Address: spec.Address, spec: spec})
+			continue
+		}
+
+		changed := false
+		if spec.AvailabilityZone != host.AvailabilityZone {
+			if len(spec.AvailabilityZone) == 0 {
+				changes = append(changes, syncChange{syncDrift, spec.Address, host.ID,
+					"availability zone cannot be removed from a host", spec, host})
+			} else {
+				changes = append(changes, syncChange{syncMove, spec.Address, host.ID,
+					fmt.Sprintf("availability zone %s -> %s", valueOrDash(availabilityZoneNames[host.AvailabilityZone]),
+						availabilityZoneNames[spec.AvailabilityZone]), spec, host})
+			}
+			changed = true
+		}
+		if !sameTags(spec.Tags, host.Tags) {
+			changes = append(changes, syncChange{syncDrift, spec.Address, host.ID,
+				fmt.Sprintf("usage tags %v -> %v, re-create the host to change them", host.Tags, spec.Tags), spec, host})
+			changed = true
+		}
+		if !sameMetadata(spec.Metadata, host.Metadata) {
+			changes = append(changes, syncChange{syncDrift, spec.Address, host.ID,
+				"metadata differs, re-create the host to change it", spec, host})
+			changed = true
+		}
+		if !changed {
+			changes = append(changes, syncChange{Action: syncKeep, Address: spec.Address, HostID: host.ID})
+		}
+	}
+
+	for i := range hosts {
+		host := &hosts[i]
+		if desired[host.Address] {
+			continue
+		}
+		detail := "not in DC Map, use --prune to delete"
+		if prune {
+			detail = "not in DC Map"
+		}
+		changes = append(changes, syncChange{syncRemove, host.Address, host.ID, detail, nil, host})
+	}
+
+	return changes
+}
+
+func sameTags(a []string, b []string) bool {
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	return strings.Join(sortedA, ",") == strings.Join(sortedB, ",")
+}
+
+func sameMetadata(a map[string]string, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func moveHost(id string, availabilityZoneID string) error {
+	setAvailabilityZoneSpec := &photon.HostSetAvailabilityZoneOperation{AvailabilityZoneId: availabilityZoneID}
+	task, err := client.Esxclient.Hosts.SetAvailabilityZone(id, setAvailabilityZoneSpec)
+	if err != nil {
+		return err
+	}
+	_, err = pollTask(task.ID)
+	return err
+}
+
+// Takes the host through suspended and maintenance mode, then deletes it
+func pruneHost(host *photon.Host) error {
+	steps := []func(string) (*photon.Task, error){}
+	switch host.State {
+	case "MAINTENANCE":
+	case "SUSPENDED":
+		steps = append(steps, client.Esxclient.Hosts.EnterMaintenanceMode)
+	default:
+		steps = append(steps, client.Esxclient.Hosts.Suspend, client.Esxclient.Hosts.EnterMaintenanceMode)
+	}
+	steps = append(steps, client.Esxclient.Hosts.Delete)
+
+	for _, step := range steps {
+		task, err := step(host.ID)
+		if err != nil {
+			return err
+		}
+		_, err = pollTask(task.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSyncChanges(changes []syncChange, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, change := range changes {
+			fmt.Printf("%s\t%s\t%s\t%s\n", change.Action, change.Address, change.HostID, change.Detail)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(changes, os.Stdout, c)
+	} else {
+		actionCount := make(map[string]int)
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Action\tIP\tHost ID\tDetail\n")
+		for _, change := range changes {
+			actionCount[change.Action]++
+			if change.Action == syncKeep {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Action, change.Address, valueOrDash(change.HostID),
+				valueOrDash(change.Detail))
+		}
+		err := w.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nUnchanged: %d, Add: %d, Move: %d, Remove: %d, Drift: %d\n", actionCount[syncKeep],
+			actionCount[syncAdd], actionCount[syncMove], actionCount[syncRemove], actionCount[syncDrift])
+	}
+	return nil
+}
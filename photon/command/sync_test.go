@@ -0,0 +1,196 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestGetSyncChanges(t *testing.T) {
+	specs := []photon.HostCreateSpec{
+		{Address: "10.0.0.1", AvailabilityZone: "zone-1", Tags: []string{"CLOUD", "MGMT"}},
+		{Address: "10.0.0.2", AvailabilityZone: "zone-2", Tags: []string{"CLOUD"}},
+		{Address: "10.0.0.3", Tags: []string{"CLOUD"}, Metadata: map[string]string{"a": "b"}},
+		{Address: "10.0.0.4"},
+	}
+	hosts := []photon.Host{
+		{ID: "host-1", Address: "10.0.0.1", AvailabilityZone: "zone-1", Tags: []string{"MGMT", "CLOUD"}},
+		{ID: "host-2", Address: "10.0.0.2", AvailabilityZone: "zone-1", Tags: []string{"CLOUD"}},
+		{ID: "host-3", Address: "10.0.0.3", Tags: []string{"CLOUD"}, Metadata: map[string]string{"a": "c"}},
+		{ID: "host-9", Address: "10.0.0.9"},
+	}
+	zoneNames := map[string]string{"zone-1": "az1", "zone-2": "az2"}
+
+	changes := getSyncChanges(specs, hosts, zoneNames, false)
+
+	expected := []struct {
+		action  string
+		address string
+	}{
+		{syncKeep, "10.0.0.1"},
+		{syncMove, "10.0.0.2"},
+		{syncDrift, "10.0.0.3"},
+		{syncAdd, "10.0.0.4"},
+		{syncRemove, "10.0.0.9"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %v", len(expected), len(changes), changes)
+	}
+	for i, change := range changes {
+		if change.Action != expected[i].action || change.Address != expected[i].address {
+			t.Errorf("Expected change %d to be %s %s, got %s %s", i, expected[i].action, expected[i].address,
+				change.Action, change.Address)
+		}
+	}
+	if changes[1].Detail != "availability zone az1 -> az2" {
+		t.Errorf("Unexpected detail for moved host: %s", changes[1].Detail)
+	}
+}
+
+func TestSyncHosts(t *testing.T) {
+	f, err := ioutil.TempFile("", "tempDcMap")
+	if err != nil {
+		t.Error("Fail to create temperory Dc_Map")
+	}
+	defer func() {
+		err = syscall.Unlink(f.Name())
+		if err != nil {
+			t.Error("Failed to unlink test dc_map file.")
+		}
+	}()
+
+	dcmap := `---
+defaults:
+  username: root
+  password: Password!
+hosts:
+  - address_ranges: 10.0.0.1
+    availability_zone: az2
+  - address_ranges: 10.0.0.2
+`
+	err = ioutil.WriteFile(f.Name(), []byte(dcmap), 0644)
+	if err != nil {
+		t.Error("Failed to create test dc_map file.")
+	}
+
+	deployments := photon.Deployments{
+		Items: []photon.Deployment{{ID: "deployment-ID"}},
+	}
+	deploymentsResponse, err := json.Marshal(deployments)
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	zones := photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-1", Name: "az1"}, {ID: "zone-2", Name: "az2"}},
+	}
+	zonesResponse, err := json.Marshal(zones)
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	hosts := MockHostsPage{
+		Items: []photon.Host{
+			{ID: "host-1", Address: "10.0.0.1", AvailabilityZone: "zone-1"},
+			{ID: "host-9", Address: "10.0.0.9", State: "MAINTENANCE"},
+		},
+	}
+	hostsResponse, err := json.Marshal(hosts)
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	createTaskID, createQueued, createCompleted, err := createTaskResponses("CREATE_HOST", "host-2")
+	if err != nil {
+		t.Error("Not expecting error serializing create host task")
+	}
+	moveTask := &photon.Task{ID: "move-task-ID", Operation: "SET_AVAILABILITY_ZONE", State: "COMPLETED",
+		Entity: photon.Entity{ID: "host-1"}}
+	moveResponse, err := json.Marshal(moveTask)
+	if err != nil {
+		t.Error("Not expecting error serializing move task")
+	}
+	deleteTask := &photon.Task{ID: "delete-task-ID", Operation: "DELETE_HOST", State: "COMPLETED",
+		Entity: photon.Entity{ID: "host-9"}}
+	deleteResponse, err := json.Marshal(deleteTask)
+	if err != nil {
+		t.Error("Not expecting error serializing delete task")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, createQueued))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+createTaskID,
+		mocks.CreateResponder(200, createCompleted))
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/hosts/host-1/set_availability_zone",
+		mocks.CreateResponder(200, string(moveResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+moveTask.ID,
+		mocks.CreateResponder(200, string(moveResponse[:])))
+	mocks.RegisterResponder(
+		"DELETE",
+		server.URL+"/hosts/host-9",
+		mocks.CreateResponder(200, string(deleteResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+deleteTask.ID,
+		mocks.CreateResponder(200, string(deleteResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("prune", true, "prune")
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	err = syncHosts(cxt)
+	if err != nil {
+		t.Error("Not expecting error syncing hosts: ", err)
+	}
+}
@@ -10,26 +10,36 @@
 package command
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
 
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
 	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
 	"github.com/vmware/photon-controller-cli/photon/manifest"
+	"github.com/vmware/photon-controller-cli/photon/utils"
 )
 
 // Create a cli.command object for command "system"
 // Subcommand: status; Usage: system status
 // Subcommand: status; Usage: system deploy <dc_map>
+// Subcommand: render; Usage: system render <dc_map>
+// Subcommand: preflight; Usage: system preflight <dc_map>
+// Subcommand: sync; Usage: system sync <dc_map> [<options>]
 func GetSystemCommand() cli.Command {
 	command := cli.Command{
 		Name:  "system",
@@ -48,6 +58,25 @@ func GetSystemCommand() cli.Command {
 			{
 				Name:  "deploy",
 				Usage: "Deploy Photon using DC Map",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Resume an interrupted deploy, skipping the work that is already done",
+					},
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: 10,
+						Usage: "Number of hosts to register at the same time",
+					},
+					cli.BoolFlag{
+						Name:  "retry-failed",
+						Usage: "Retry the registration of hosts that failed once more",
+					},
+					cli.BoolFlag{
+						Name:  "skip-preflight",
+						Usage: "Do not run the pre-flight checks before deploying",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := deploy(c)
 					if err != nil {
@@ -55,9 +84,40 @@ func GetSystemCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "preflight",
+				Usage: "Check that the environment described by a DC Map is ready to deploy",
+				Action: func(c *cli.Context) {
+					err := preflight(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "render",
+				Usage: "Print DC Map with includes and defaults resolved and passwords masked",
+				Action: func(c *cli.Context) {
+					err := render(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "addHosts",
 				Usage: "Add multiple hosts",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: 10,
+						Usage: "Number of hosts to register at the same time",
+					},
+					cli.BoolFlag{
+						Name:  "retry-failed",
+						Usage: "Retry the registration of hosts that failed once more",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := addHosts(c)
 					if err != nil {
@@ -65,9 +125,60 @@ func GetSystemCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "sync",
+				Usage: "Reconcile the hosts of a deployment with a DC Map",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "deployment",
+						Usage: "ID of the deployment, required if there are multiple deployments",
+					},
+					cli.BoolFlag{
+						Name:  "prune",
+						Usage: "Delete hosts that are not in the DC Map",
+					},
+					cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "List the changes without applying them",
+					},
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: 10,
+						Usage: "Number of hosts to register at the same time",
+					},
+					cli.BoolFlag{
+						Name:  "retry-failed",
+						Usage: "Retry the registration of hosts that failed once more",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := syncHosts(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "destroy",
 				Usage: "destroy Photon deployment",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "deployment",
+						Usage: "Only destroy the deployment with this ID",
+					},
+					cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "List what would be removed without removing anything",
+					},
+					cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Do not ask to type the deployment ID to confirm",
+					},
+					cli.BoolFlag{
+						Name:  "keep-availability-zones",
+						Usage: "Do not delete availability zones",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := destroy(c)
 					if err != nil {
@@ -157,8 +268,9 @@ func printStatus(status *photon.Status) error {
 }
 
 // Deploy Photon Controller based on DC_map
-func deploy(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "system deploy <file>")
+// Progress is recorded in a journal so that a failed deploy can be continued with --resume
+func deploy(c *cli.Context) (err error) {
+	err = checkArgNum(c.Args(), 1, "system deploy <file>")
 	if err != nil {
 		return err
 	}
@@ -167,25 +279,101 @@ func deploy(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("skip-preflight") {
+		err = runPreflight(dcMap, c)
+		if err != nil {
+			return fmt.Errorf("%s, use --skip-preflight to deploy anyway", err)
+		}
+	}
 
 	client.Esxclient, err = client.GetClient(false)
 	if err != nil {
 		return err
 	}
 
-	deploymentID, err := createDeploymentFromDcMap(dcMap)
+	journal, err := cf.LoadDeploymentJournal()
+	if err != nil {
+		return err
+	}
+	if c.Bool("resume") {
+		if journal == nil {
+			return fmt.Errorf("No interrupted deploy found to resume")
+		}
+		if journal.DcMap != file {
+			return fmt.Errorf("Interrupted deploy used DC Map '%s', not '%s'", journal.DcMap, file)
+		}
+	} else {
+		if journal != nil {
+			return fmt.Errorf("A deploy of DC Map '%s' was interrupted, run 'system deploy --resume %s' to continue it",
+				journal.DcMap, journal.DcMap)
+		}
+		journal = cf.NewDeploymentJournal(file)
+	}
+
+	// The journal is saved after every completed step, so that it survives the CLI being killed
+	saveJournal := func() error {
+		return cf.SaveDeploymentJournal(journal)
+	}
+	err = saveJournal()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = cf.RemoveDeploymentJournal()
+			return
+		}
+		fmt.Printf("Run 'system deploy --resume %s' to continue the deploy.\n", file)
+	}()
+
+	deployed := false
+	if c.Bool("resume") {
+		deployed, err = reconcileDeploymentJournal(journal)
+		if err != nil {
+			return err
+		}
+		err = saveJournal()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(journal.DeploymentID) == 0 {
+		journal.DeploymentID, err = createDeploymentFromDcMap(dcMap)
+		if err != nil {
+			return err
+		}
+		err = saveJournal()
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Using existing deployment %s\n", journal.DeploymentID)
+	}
+
+	// Create Availability Zones
+	err = createAvailabilityZonesFromDcMap(dcMap, journal.AvailabilityZones, saveJournal)
 	if err != nil {
 		return err
 	}
 
 	// Create Hosts
-	err = createHostsFromDcMap(dcMap, deploymentID)
+	err = createHostsFromDcMap(dcMap, journal.DeploymentID, journal.AvailabilityZones, journal.Hosts, saveJournal, c)
 	if err != nil {
 		return err
 	}
 
 	// Deploy
-	err = doDeploy(dcMap, deploymentID)
+	if deployed {
+		fmt.Printf("Deployment '%s' is already deployed.\n", journal.DeploymentID)
+		return nil
+	}
+	err = doDeploy(dcMap, journal.DeploymentID)
 	if err != nil {
 		return err
 	}
@@ -193,6 +381,79 @@ func deploy(c *cli.Context) error {
 	return nil
 }
 
+// Brings the journal of an interrupted deploy in line with what exists on the server.
+// Returns true if the deployment has already been deployed.
+func reconcileDeploymentJournal(journal *cf.DeploymentJournal) (bool, error) {
+	deployments, err := client.Esxclient.Deployments.GetAll()
+	if err != nil {
+		return false, err
+	}
+
+	var deployment *photon.Deployment
+	for i := range deployments.Items {
+		if deployments.Items[i].ID == journal.DeploymentID {
+			deployment = &deployments.Items[i]
+		}
+	}
+	if deployment == nil && len(journal.DeploymentID) == 0 && len(deployments.Items) == 1 {
+		// The deployment was created but the CLI stopped before recording it
+		deployment = &deployments.Items[0]
+	}
+	if deployment == nil {
+		if len(journal.DeploymentID) != 0 {
+			fmt.Printf("Deployment '%s' no longer exists, a new one will be created\n", journal.DeploymentID)
+		}
+		journal.DeploymentID = ""
+		journal.Hosts = make(map[string]string)
+	} else {
+		journal.DeploymentID = deployment.ID
+	}
+
+	zones, err := client.Esxclient.AvailabilityZones.GetAll()
+	if err != nil {
+		return false, err
+	}
+	journal.AvailabilityZones = make(map[string]string)
+	for _, zone := range zones.Items {
+		journal.AvailabilityZones[zone.Name] = zone.ID
+	}
+
+	if deployment == nil {
+		return false, nil
+	}
+
+	hosts, err := client.Esxclient.Deployments.GetHosts(deployment.ID)
+	if err != nil {
+		return false, err
+	}
+	journal.Hosts = make(map[string]string)
+	for _, host := range hosts.Items {
+		journal.Hosts[host.Address] = host.ID
+	}
+
+	return deployment.State == "READY", nil
+}
+
+// Print the merged DC map, as it is used by deploy and addHosts, with the passwords masked
+func render(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "system render <file>")
+	if err != nil {
+		return err
+	}
+	dcMap, err := manifest.LoadInstallation(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	dcMap.MaskPasswords()
+	out, err := manifest.RenderInstallation(dcMap)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "---\n%s", out)
+	return nil
+}
+
 // Add most hosts in batch mode
 func addHosts(c *cli.Context) error {
 	err := checkArgNum(c.Args(), 1, "system addHosts <file>")
@@ -210,11 +471,19 @@ func addHosts(c *cli.Context) error {
 		return err
 	}
 
-	deployments, err := client.Esxclient.Deployments.GetAll()
-	deploymentID := deployments.Items[0].ID
+	deploymentID, err := findDeploymentID("")
+	if err != nil {
+		return err
+	}
+
+	availabilityZoneNameToIdMap := make(map[string]string)
+	err = createAvailabilityZonesFromDcMap(dcMap, availabilityZoneNameToIdMap, nil)
+	if err != nil {
+		return err
+	}
 
 	// Create Hosts
-	err = createHostsInBatch(dcMap, deploymentID)
+	err = createHostsFromDcMap(dcMap, deploymentID, availabilityZoneNameToIdMap, make(map[string]string), nil, c)
 	if err != nil {
 		return err
 	}
@@ -222,6 +491,21 @@ func addHosts(c *cli.Context) error {
 	return nil
 }
 
+// Entity removed by system destroy
+type destroyPlanItem struct {
+	Kind  string `json:"kind"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Host that could not be deleted by system destroy
+type hostDeleteFailure struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
 // Destroy a Photon Controller deployment
 func destroy(c *cli.Context) error {
 	err := checkArgNum(c.Args(), 0, "system destroy")
@@ -229,48 +513,140 @@ func destroy(c *cli.Context) error {
 		return err
 	}
 
-	client.Esxclient, err = client.GetClient(false)
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
 	if err != nil {
 		return err
 	}
 
 	deployments, err := client.Esxclient.Deployments.GetAll()
+	if err != nil {
+		return err
+	}
 
-	// Destroy deployment
-	for _, deployment := range deployments.Items {
-		err = doDestroy(deployment.ID)
-		if err != nil {
-			return err
+	targets := deployments.Items
+	deploymentID := c.String("deployment")
+	if len(deploymentID) != 0 {
+		targets = nil
+		for _, deployment := range deployments.Items {
+			if deployment.ID == deploymentID {
+				targets = append(targets, deployment)
+			}
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("Deployment '%s' not found", deploymentID)
 		}
 	}
+	if len(targets) == 0 {
+		fmt.Printf("No deployment to destroy\n")
+		return nil
+	}
 
-	// Delete hosts
-	for _, deployment := range deployments.Items {
+	// Collect everything that is going to be removed
+	var plan []destroyPlanItem
+	hostsByDeployment := make(map[string][]photon.Host)
+	usedZones := make(map[string]bool)
+	for _, deployment := range targets {
+		plan = append(plan, destroyPlanItem{"deployment", deployment.ID, "", deployment.State})
 		hosts, err := client.Esxclient.Deployments.GetHosts(deployment.ID)
 		if err != nil {
 			return err
 		}
-
+		hostsByDeployment[deployment.ID] = hosts.Items
 		for _, host := range hosts.Items {
-			deleteTask, err := client.Esxclient.Hosts.Delete(host.ID)
+			plan = append(plan, destroyPlanItem{"host", host.ID, host.Address, host.State})
+			usedZones[host.AvailabilityZone] = true
+		}
+	}
+
+	// Zones are shared, the ones still used by hosts of the other deployments are kept
+	keptZones := make(map[string]bool)
+	if len(deploymentID) != 0 && !c.Bool("keep-availability-zones") {
+		for _, deployment := range deployments.Items {
+			if deployment.ID == deploymentID {
+				continue
+			}
+			hosts, err := client.Esxclient.Deployments.GetHosts(deployment.ID)
 			if err != nil {
 				return err
 			}
+			for _, host := range hosts.Items {
+				keptZones[host.AvailabilityZone] = true
+			}
+		}
+	}
 
-			deleteTask, err = pollTask(deleteTask.ID)
+	var zones []photon.AvailabilityZone
+	if !c.Bool("keep-availability-zones") {
+		allZones, err := client.Esxclient.AvailabilityZones.GetAll()
+		if err != nil {
+			return err
+		}
+		for _, zone := range allZones.Items {
+			if len(deploymentID) == 0 || (usedZones[zone.ID] && !keptZones[zone.ID]) {
+				zones = append(zones, zone)
+				plan = append(plan, destroyPlanItem{"availability-zone", zone.ID, zone.Name, zone.State})
+			}
+		}
+	}
+
+	if c.Bool("dry-run") {
+		return printDestroyPlan(plan, c)
+	}
+
+	if !c.Bool("yes") {
+		if utils.IsNonInteractive(c) {
+			return fmt.Errorf("Use --yes to destroy in non-interactive mode")
+		}
+		err = printDestroyPlan(plan, c)
+		if err != nil {
+			return err
+		}
+		for _, deployment := range targets {
+			response, err := askForInput(
+				fmt.Sprintf("\nType the deployment ID '%s' to confirm: ", deployment.ID), "")
 			if err != nil {
 				return err
 			}
+			if response != deployment.ID {
+				return fmt.Errorf("Deployment ID did not match, nothing was destroyed")
+			}
+		}
+	}
+
+	// Destroy deployment
+	for _, deployment := range targets {
+		err = doDestroy(deployment.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Delete hosts, collecting the failures so that one bad host does not stop the others
+	var hostFailures []hostDeleteFailure
+	for _, deployment := range targets {
+		for _, host := range hostsByDeployment[deployment.ID] {
+			deleteTask, err := client.Esxclient.Hosts.Delete(host.ID)
+			if err == nil {
+				deleteTask, err = pollTask(deleteTask.ID)
+			}
+			if err != nil {
+				hostFailures = append(hostFailures, hostDeleteFailure{host.ID, host.Address, err.Error()})
+				continue
+			}
 			fmt.Printf("Host has been deleted: ID = %s\n", deleteTask.Entity.ID)
 		}
 	}
+	if len(hostFailures) != 0 {
+		err = printHostDeleteFailures(hostFailures, c)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%d hosts could not be deleted, availability zones and deployments were kept",
+			len(hostFailures))
+	}
 
 	// Delete availability-zones
-	zones, err := client.Esxclient.AvailabilityZones.GetAll()
-	if err != nil {
-		return err
-	}
-	for _, zone := range zones.Items {
+	for _, zone := range zones {
 		deleteTask, err := client.Esxclient.AvailabilityZones.Delete(zone.ID)
 		if err != nil {
 			return err
@@ -284,7 +660,7 @@ func destroy(c *cli.Context) error {
 	}
 
 	// Delete deployment doc
-	for _, deployment := range deployments.Items {
+	for _, deployment := range targets {
 		deleteTask, err := client.Esxclient.Deployments.Delete(deployment.ID)
 		if err != nil {
 			return err
@@ -300,6 +676,53 @@ func destroy(c *cli.Context) error {
 	return nil
 }
 
+func printDestroyPlan(plan []destroyPlanItem, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, item := range plan {
+			fmt.Printf("%s\t%s\t%s\t%s\n", item.Kind, item.ID, item.Name, item.State)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(plan, os.Stdout, c)
+	} else {
+		fmt.Printf("The following will be removed:\n")
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Kind\tID\tName\tState\n")
+		for _, item := range plan {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Kind, item.ID, valueOrDash(item.Name), valueOrDash(item.State))
+		}
+		err := w.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nTotal: %d\n", len(plan))
+	}
+	return nil
+}
+
+func printHostDeleteFailures(failures []hostDeleteFailure, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, failure := range failures {
+			fmt.Printf("%s\t%s\t%s\n", failure.ID, failure.Address, failure.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(failures, os.Stdout, c)
+	} else {
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Host ID\tIP\tError\n")
+		for _, failure := range failures {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", failure.ID, failure.Address,
+				strings.Replace(failure.Error, "\n", " ", -1))
+		}
+		err := w.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Starts the recurring copy state of source system into destination
 func deploymentMigrationPrepareDeprecated(c *cli.Context) error {
 	err := checkArgNum(c.Args(), 1, "system migration prepare <old_management_endpoint>")
@@ -482,8 +905,11 @@ func contains(list []string, value string) bool {
 	return false
 }
 
-func createAvailabilityZonesFromDcMap(dcMap *manifest.Installation) (map[string]string, error) {
-	availabilityZoneNameToIdMap := make(map[string]string)
+// Creates the availability zones used by the DC map hosts that are not in the given
+// name to ID map yet, and adds them to it. saveProgress, if not nil, is called after
+// each zone is created.
+func createAvailabilityZonesFromDcMap(dcMap *manifest.Installation, availabilityZoneNameToIdMap map[string]string,
+	saveProgress func() error) error {
 	for _, host := range dcMap.Hosts {
 		if len(host.AvailabilityZone) > 0 {
 			if _, present := availabilityZoneNameToIdMap[host.AvailabilityZone]; !present {
@@ -493,80 +919,186 @@ func createAvailabilityZonesFromDcMap(dcMap *manifest.Installation) (map[string]
 
 				createAvailabilityZoneTask, err := client.Esxclient.AvailabilityZones.Create(availabilityZoneSpec)
 				if err != nil {
-					return nil, err
+					return err
 				}
 
 				task, err := pollTask(createAvailabilityZoneTask.ID)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				availabilityZoneNameToIdMap[host.AvailabilityZone] = task.Entity.ID
+				if saveProgress != nil {
+					err = saveProgress()
+					if err != nil {
+						return err
+					}
+				}
 			}
 		}
 	}
-	return availabilityZoneNameToIdMap, nil
+	return nil
+}
+
+// Outcome of the registration of a single host
+type hostCreationResult struct {
+	Address string `json:"address"`
+	HostID  string `json:"hostId"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
 }
 
-func createHostsFromDcMap(dcMap *manifest.Installation, deploymentID string) error {
-	hostSpecs, err := createHostSpecs(dcMap)
+// Creates the DC map hosts whose address is not in the given address to ID map yet,
+// and adds them to it. saveProgress, if not nil, is called after each host is created.
+// Returns an error if any of the hosts failed to be created.
+func createHostsFromDcMap(dcMap *manifest.Installation, deploymentID string,
+	availabilityZoneNameToIdMap map[string]string, createdHosts map[string]string,
+	saveProgress func() error, c *cli.Context) error {
+	hostSpecs, err := createHostSpecs(dcMap, availabilityZoneNameToIdMap)
 	if err != nil {
 		return err
 	}
 
+	var pendingSpecs []photon.HostCreateSpec
 	for _, spec := range hostSpecs {
-		createHostTask, err := client.Esxclient.Hosts.Create(&spec, deploymentID)
-		if err != nil {
-			return err
+		if id, present := createdHosts[spec.Address]; present {
+			fmt.Printf("Host with ip '%s' already exists: ID = %s\n", spec.Address, id)
+			continue
 		}
+		pendingSpecs = append(pendingSpecs, spec)
+	}
+	if len(pendingSpecs) == 0 {
+		return nil
+	}
 
-		task, err := pollTask(createHostTask.ID)
-		if err != nil {
-			return err
+	var saveErr error
+	_, err = registerHosts(pendingSpecs, deploymentID, func(address string, id string) {
+		createdHosts[address] = id
+		if saveProgress != nil && saveErr == nil {
+			saveErr = saveProgress()
 		}
-		fmt.Printf("Host with ip '%s' created: ID = %s\n", spec.Address, task.Entity.ID)
+	}, c, os.Stdout)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return saveErr
 }
 
-func createHostsInBatch(dcMap *manifest.Installation, deploymentID string) error {
-	hostSpecs, err := createHostSpecs(dcMap)
+// Creates the hosts, retrying the failed ones if requested, and prints the result
+// of each host to w. onCreated, if not nil, is called once for each created host, never
+// concurrently. Returns an error if any of the hosts failed to be created.
+func registerHosts(hostSpecs []photon.HostCreateSpec, deploymentID string, onCreated func(address string, id string),
+	c *cli.Context, w io.Writer) ([]hostCreationResult, error) {
+	isScripting := utils.IsNonInteractive(c)
+	results := createHostsInParallel(hostSpecs, deploymentID, c.Int("parallel"), isScripting, onCreated)
+
+	if c.Bool("retry-failed") {
+		var retrySpecs []photon.HostCreateSpec
+		var retryIndexes []int
+		for i, result := range results {
+			if len(result.Error) != 0 {
+				retrySpecs = append(retrySpecs, hostSpecs[i])
+				retryIndexes = append(retryIndexes, i)
+			}
+		}
+		if len(retrySpecs) != 0 {
+			if !isScripting {
+				fmt.Fprintf(w, "Retrying %d failed hosts\n", len(retrySpecs))
+			}
+			retryResults := createHostsInParallel(retrySpecs, deploymentID, c.Int("parallel"), isScripting, onCreated)
+			for i, result := range retryResults {
+				results[retryIndexes[i]] = result
+			}
+		}
+	}
+
+	err := printHostCreationResults(results, c, w)
 	if err != nil {
-		return err
+		return results, err
 	}
 
-	createTaskMap := make(map[string]*photon.Task)
-	var creationErrors []error
-	var pollErrors []error
-	for _, spec := range hostSpecs {
-		createHostTask, err := client.Esxclient.Hosts.Create(&spec, deploymentID)
-		if err != nil {
-			creationErrors = append(creationErrors, err)
-			fmt.Printf("Creation of Host document with ip '%s' failed: with err '%s'\n",
-				spec.Address, err)
-		} else {
-			createTaskMap[spec.Address] = createHostTask
+	failed := 0
+	for _, result := range results {
+		if len(result.Error) != 0 {
+			failed++
 		}
 	}
+	if failed != 0 {
+		return results, fmt.Errorf("%d of %d hosts failed to be created", failed, len(results))
+	}
+	return results, nil
+}
 
-	for address, createTask := range createTaskMap {
-		task, err := pollTask(createTask.ID)
+// Creates the hosts with at most parallel creations running at the same time
+func createHostsInParallel(hostSpecs []photon.HostCreateSpec, deploymentID string, parallel int,
+	isScripting bool, onCreated func(address string, id string)) []hostCreationResult {
+	results := make([]hostCreationResult, len(hostSpecs))
+	var mutex sync.Mutex
+	runInParallel(len(hostSpecs), parallel, "CREATE_HOST", isScripting, func(i int) error {
+		spec := hostSpecs[i]
+		results[i].Address = spec.Address
+
+		task, err := client.Esxclient.Hosts.Create(&spec, deploymentID)
+		if err == nil {
+			task, err = client.Esxclient.Tasks.Wait(task.ID)
+		}
+		if task != nil {
+			results[i].HostID = task.Entity.ID
+		}
 		if err != nil {
-			pollErrors = append(pollErrors, err)
-			fmt.Printf("Creation of Host with ip '%s' failed: ID = %s with err '%s'\n\n",
-				address, task.ID, err)
-		} else {
-			fmt.Printf("Host with ip '%s' created: ID = %s\n\n", address, task.Entity.ID)
+			results[i].Error = strings.TrimSpace(strings.Replace(err.Error(), "\n", " ", -1))
+			return err
+		}
+		if onCreated != nil {
+			mutex.Lock()
+			onCreated(spec.Address, task.Entity.ID)
+			mutex.Unlock()
+		}
+
+		host, err := client.Esxclient.Hosts.Get(task.Entity.ID)
+		if err == nil {
+			results[i].State = host.State
+		}
+		return nil
+	})
+	return results
+}
+
+func printHostCreationResults(results []hostCreationResult, c *cli.Context, w io.Writer) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Address, result.HostID, result.State, result.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(results, w, c)
+	} else {
+		failed := 0
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "IP\tHost ID\tState\tError\n")
+		for _, result := range results {
+			if len(result.Error) != 0 {
+				failed++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Address, valueOrDash(result.HostID),
+				valueOrDash(result.State), valueOrDash(result.Error))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
 		}
+		fmt.Fprintf(w, "\nTotal: %d, Failed: %d\n", len(results), failed)
 	}
 	return nil
 }
 
-func createHostSpecs(dcMap *manifest.Installation) ([]photon.HostCreateSpec, error) {
-	availabilityZoneNameToIdMap, err := createAvailabilityZonesFromDcMap(dcMap)
-	if err != nil {
-		return nil, err
+func valueOrDash(value string) string {
+	if len(value) == 0 {
+		return "-"
 	}
+	return value
+}
+
+func createHostSpecs(dcMap *manifest.Installation, availabilityZoneNameToIdMap map[string]string) ([]photon.HostCreateSpec, error) {
 	var hostSpecs []photon.HostCreateSpec
 	var managementNetworkIps []string
 	for _, host := range dcMap.Hosts {
@@ -617,39 +1149,178 @@ func createHostSpecs(dcMap *manifest.Installation) ([]photon.HostCreateSpec, err
 	return hostSpecs, nil
 }
 
+// Maximum number of addresses a DC map address list may expand to
+const maxIpRangeAddresses = 4096
+
+// Inclusive range of IPv4 or IPv6 addresses
+type ipRange struct {
+	start *big.Int
+	end   *big.Int
+	ipv4  bool
+}
+
+// Expands a comma separated list of addresses into single addresses. Each entry is an
+// IPv4 or IPv6 address, a range "<first>-<last>" or a CIDR block. IPv4 CIDR blocks skip
+// their network and broadcast address. Entries starting with "!" are excluded from
+// the result.
 func parseIpRanges(ipRanges string) ([]string, error) {
+	var includes []*ipRange
+	var excludes []*ipRange
+	for _, entry := range regexp.MustCompile(`\s*,\s*`).Split(strings.TrimSpace(ipRanges), -1) {
+		exclude := strings.HasPrefix(entry, "!")
+		if exclude {
+			entry = strings.TrimSpace(strings.TrimPrefix(entry, "!"))
+		}
+		r, err := parseIpRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			excludes = append(excludes, r)
+		} else {
+			includes = append(includes, r)
+		}
+	}
+
+	total := new(big.Int)
+	for _, r := range includes {
+		total.Add(total, new(big.Int).Sub(r.end, r.start))
+		total.Add(total, big.NewInt(1))
+	}
+	if total.Cmp(big.NewInt(maxIpRangeAddresses)) > 0 {
+		return nil, fmt.Errorf("Address range '%s' defined in DC Map expands to %s addresses, more than the %d allowed",
+			ipRanges, total.String(), maxIpRangeAddresses)
+	}
+
 	var ipList []string
-	for _, ipRange := range regexp.MustCompile(`\s*,\s*`).Split(ipRanges, -1) {
-		ips := regexp.MustCompile(`\s*-\s*`).Split(ipRange, -1)
-		if len(ips) == 1 {
-			ip := net.ParseIP(ips[0]).To4()
-			if ip == nil {
-				return nil, errors.New("Bad IP Address defined in DC Map")
-			}
-			ipList = append(ipList, ips[0])
-		} else if len(ips) == 2 {
-			ip0 := net.ParseIP(ips[0]).To4()
-			ip1 := net.ParseIP(ips[1]).To4()
-			if ip0 == nil || ip1 == nil {
-				return nil, errors.New("Bad IP Address defined in DC Map")
+	one := big.NewInt(1)
+	for _, r := range includes {
+		for ip := new(big.Int).Set(r.start); ip.Cmp(r.end) <= 0; ip.Add(ip, one) {
+			if !isExcluded(ip, r.ipv4, excludes) {
+				ipList = append(ipList, bigIntToIp(ip, r.ipv4).String())
 			}
-			for ip := ip0; bytes.Compare(ip, ip1) <= 0; inc(ip) {
-				ipList = append(ipList, ip.String())
-			}
-		} else {
-			return nil, errors.New("Bad Address Range defined in DC Map")
 		}
 	}
 	return ipList, nil
 }
 
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
+func parseIpRange(entry string) (*ipRange, error) {
+	if strings.Contains(entry, "/") {
+		ip, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.New("Bad Address Range defined in DC Map")
+		}
+		ipv4 := ip.To4() != nil
+		ones, bits := network.Mask.Size()
+		start := ipToBigInt(network.IP)
+		end := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+		end.Sub(end, big.NewInt(1))
+		end.Or(end, start)
+		if ipv4 && ones <= 30 {
+			start.Add(start, big.NewInt(1))
+			end.Sub(end, big.NewInt(1))
+		}
+		return &ipRange{start, end, ipv4}, nil
+	}
+
+	ips := regexp.MustCompile(`\s*-\s*`).Split(entry, -1)
+	if len(ips) > 2 {
+		return nil, errors.New("Bad Address Range defined in DC Map")
+	}
+	first := net.ParseIP(ips[0])
+	last := first
+	if len(ips) == 2 {
+		last = net.ParseIP(ips[1])
+	}
+	if first == nil || last == nil {
+		return nil, errors.New("Bad IP Address defined in DC Map")
+	}
+	ipv4 := first.To4() != nil
+	if ipv4 != (last.To4() != nil) {
+		return nil, errors.New("Bad Address Range defined in DC Map")
+	}
+
+	r := &ipRange{ipToBigInt(first), ipToBigInt(last), ipv4}
+	if r.start.Cmp(r.end) > 0 {
+		return nil, errors.New("Bad Address Range defined in DC Map")
+	}
+	return r, nil
+}
+
+func isExcluded(ip *big.Int, ipv4 bool, excludes []*ipRange) bool {
+	for _, r := range excludes {
+		if r.ipv4 == ipv4 && ip.Cmp(r.start) >= 0 && ip.Cmp(r.end) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func ipToBigInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+func bigIntToIp(value *big.Int, ipv4 bool) net.IP {
+	size := net.IPv6len
+	if ipv4 {
+		size = net.IPv4len
+	}
+	buf := value.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(buf):], buf)
+	return ip
+}
+
+// Inverse of parseIpRanges, sorts the addresses and joins consecutive ones into ranges
+func compressIpRanges(ips []string) (string, error) {
+	var addresses []*ipRange
+	for _, address := range ips {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return "", fmt.Errorf("Bad IP Address '%s'", address)
+		}
+		value := ipToBigInt(ip)
+		addresses = append(addresses, &ipRange{value, value, ip.To4() != nil})
+	}
+	sort.Sort(ipRangeSorter(addresses))
+
+	var ranges []*ipRange
+	one := big.NewInt(1)
+	for _, address := range addresses {
+		if len(ranges) != 0 {
+			last := ranges[len(ranges)-1]
+			if last.ipv4 == address.ipv4 && new(big.Int).Add(last.end, one).Cmp(address.start) >= 0 {
+				last.end = address.end
+				continue
+			}
+		}
+		ranges = append(ranges, address)
+	}
+
+	var entries []string
+	for _, r := range ranges {
+		if r.start.Cmp(r.end) == 0 {
+			entries = append(entries, bigIntToIp(r.start, r.ipv4).String())
+		} else {
+			entries = append(entries, bigIntToIp(r.start, r.ipv4).String()+"-"+bigIntToIp(r.end, r.ipv4).String())
 		}
 	}
+	return strings.Join(entries, ", "), nil
+}
+
+// IPv4 ranges sort before IPv6 ranges
+type ipRangeSorter []*ipRange
+
+func (r ipRangeSorter) Len() int      { return len(r) }
+func (r ipRangeSorter) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r ipRangeSorter) Less(i, j int) bool {
+	if r[i].ipv4 != r[j].ipv4 {
+		return r[i].ipv4
+	}
+	return r[i].start.Cmp(r[j].start) < 0
 }
 
 func doDeploy(installSpec *manifest.Installation, deploymentID string) error {
@@ -10,12 +10,17 @@
 package command
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -72,6 +77,16 @@ func TestGetStatus(t *testing.T) {
 }
 
 func TestDeploy(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "config-test-")
+	if err != nil {
+		t.Error("Not expecting error creating config directory")
+	}
+	defer func() {
+		_ = os.RemoveAll(configDir)
+		cf.UserConfigDir = ""
+	}()
+	cf.UserConfigDir = configDir
+
 	f, err := ioutil.TempFile("", "tempDcMap")
 	if err != nil {
 		t.Error("Fail to create temperory Dc_Map")
@@ -133,6 +148,7 @@ hosts:
 	}
 
 	set := flag.NewFlagSet("test", 0)
+	set.Bool("skip-preflight", true, "skip-preflight")
 	cxt := cli.NewContext(nil, set, nil)
 	err = set.Parse([]string{f.Name()})
 	if err != nil {
@@ -209,18 +225,30 @@ hosts:
 		"GET",
 		server.URL+"/tasks/"+availZoneQueuedTaskId,
 		mocks.CreateResponder(200, availZoneTaskResponse))
+	// by the time hosts are created, the journal records the deployment and the availability zone
+	var journalBeforeHosts *cf.DeploymentJournal
 	mocks.RegisterResponder(
 		"POST",
 		server.URL+"/deployments/deployment-ID/hosts",
-		mocks.CreateResponder(200, hostResponse))
+		func(req *http.Request) (*http.Response, error) {
+			if journalBeforeHosts == nil {
+				journalBeforeHosts, _ = cf.LoadDeploymentJournal()
+			}
+			return mocks.CreateResponder(200, hostResponse)(req)
+		})
 	mocks.RegisterResponder(
 		"GET",
 		server.URL+"/tasks/"+hostQueuedTaskId,
 		mocks.CreateResponder(200, hostTaskResponse))
+	// by the time the deployment is deployed, the journal records the hosts
+	var journalBeforeDeploy *cf.DeploymentJournal
 	mocks.RegisterResponder(
 		"POST",
 		server.URL+"/deployments/deployment-ID/deploy",
-		mocks.CreateResponder(200, deployResponse))
+		func(req *http.Request) (*http.Response, error) {
+			journalBeforeDeploy, _ = cf.LoadDeploymentJournal()
+			return mocks.CreateResponder(200, deployResponse)(req)
+		})
 	mocks.RegisterResponder(
 		"GET",
 		server.URL+"/tasks/"+deployQueuedTaskId,
@@ -238,6 +266,289 @@ hosts:
 	if err != nil {
 		t.Error(err)
 	}
+	if journalBeforeHosts == nil || journalBeforeHosts.DeploymentID != "deployment-ID" ||
+		len(journalBeforeHosts.AvailabilityZones["Zone1"]) == 0 {
+		t.Errorf("Expected the journal to be saved before creating hosts, got %+v", journalBeforeHosts)
+	}
+	if journalBeforeDeploy == nil || len(journalBeforeDeploy.Hosts) != 4 {
+		t.Errorf("Expected the journal to record the hosts before deploying, got %+v", journalBeforeDeploy)
+	}
+}
+
+func TestDeployResume(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "config-test-")
+	if err != nil {
+		t.Error("Not expecting error creating config directory")
+	}
+	defer func() {
+		_ = os.RemoveAll(configDir)
+		cf.UserConfigDir = ""
+	}()
+	cf.UserConfigDir = configDir
+
+	f, err := ioutil.TempFile("", "tempDcMap")
+	if err != nil {
+		t.Error("Fail to create temperory Dc_Map")
+	}
+	defer func() {
+		err = syscall.Unlink(f.Name())
+		if err != nil {
+			t.Error("Failed to unlink test dc_map file.")
+		}
+	}()
+
+	dcmap := `---
+deployment:
+  resume_system: true
+  image_datastores: datastore1
+hosts:
+  - address_ranges: 10.146.38.91-10.146.38.92
+    username: root
+    password: Password!
+    availability_zone: Zone1
+`
+	err = ioutil.WriteFile(f.Name(), []byte(dcmap), 0644)
+	if err != nil {
+		t.Error("Failed to create test dc_map file.")
+	}
+	dcMapPath, err := filepath.Abs(f.Name())
+	if err != nil {
+		t.Error("Not expecting error getting DC map path")
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("resume", true, "resume")
+	set.Bool("skip-preflight", true, "skip-preflight")
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	// resuming without a journal fails
+	err = deploy(cxt)
+	if err == nil {
+		t.Error("Expected error resuming deploy without journal")
+	}
+
+	journal := cf.NewDeploymentJournal(dcMapPath)
+	journal.DeploymentID = "deployment-ID"
+	err = cf.SaveDeploymentJournal(journal)
+	if err != nil {
+		t.Error("Not expecting error saving deployment journal")
+	}
+
+	deployments := photon.Deployments{
+		Items: []photon.Deployment{{ID: "deployment-ID", State: "READY"}},
+	}
+	deploymentsResponse, err := json.Marshal(deployments)
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	zones := photon.AvailabilityZones{
+		Items: []photon.AvailabilityZone{{ID: "zone-ID", Name: "Zone1"}},
+	}
+	zonesResponse, err := json.Marshal(zones)
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+	hosts := MockHostsPage{
+		Items: []photon.Host{
+			{ID: "host-ID-1", Address: "10.146.38.91"},
+			{ID: "host-ID-2", Address: "10.146.38.92"},
+		},
+	}
+	hostsResponse, err := json.Marshal(hosts)
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, string(hostsResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	// everything already exists, so nothing is created
+	err = deploy(cxt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	journal, err = cf.LoadDeploymentJournal()
+	if err != nil || journal != nil {
+		t.Error("Expected deployment journal to be removed after deploy completed")
+	}
+
+	// a new deploy does not overwrite the journal of an interrupted one
+	journal = cf.NewDeploymentJournal(dcMapPath)
+	journal.DeploymentID = "deployment-ID"
+	err = cf.SaveDeploymentJournal(journal)
+	if err != nil {
+		t.Error("Not expecting error saving deployment journal")
+	}
+	set = flag.NewFlagSet("test", 0)
+	set.Bool("skip-preflight", true, "skip-preflight")
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = deploy(cli.NewContext(nil, set, nil))
+	if err == nil {
+		t.Error("Expected a deploy without --resume to fail while a journal exists")
+	}
+	journal, err = cf.LoadDeploymentJournal()
+	if err != nil || journal == nil || journal.DeploymentID != "deployment-ID" {
+		t.Errorf("Expected the journal of the interrupted deploy to be kept, got %+v", journal)
+	}
+}
+
+func TestRender(t *testing.T) {
+	f, err := ioutil.TempFile("", "tempDcMap")
+	if err != nil {
+		t.Error("Fail to create temperory Dc_Map")
+	}
+	defer func() {
+		err = syscall.Unlink(f.Name())
+		if err != nil {
+			t.Error("Failed to unlink test dc_map file.")
+		}
+	}()
+
+	dcmap := `---
+defaults:
+  username: root
+  password: Password!
+deployment:
+  image_datastores: datastore1
+hosts:
+  - address_ranges: 10.146.38.91
+`
+	err = ioutil.WriteFile(f.Name(), []byte(dcmap), 0644)
+	if err != nil {
+		t.Error("Failed to create test dc_map file.")
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	var buf bytes.Buffer
+	err = render(cxt, &buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(buf.String(), "Password!") || !strings.Contains(buf.String(), "password: '********'") {
+		t.Errorf("Expected the rendered DC map to mask the passwords, got %s", buf.String())
+	}
+}
+
+func TestAddHostsWithFailures(t *testing.T) {
+	f, err := ioutil.TempFile("", "tempDcMap")
+	if err != nil {
+		t.Error("Fail to create temperory Dc_Map")
+	}
+	defer func() {
+		err = syscall.Unlink(f.Name())
+		if err != nil {
+			t.Error("Failed to unlink test dc_map file.")
+		}
+	}()
+
+	dcmap := `---
+hosts:
+  - address_ranges: 10.146.38.91-10.146.38.93
+    username: root
+    password: Password!
+`
+	err = ioutil.WriteFile(f.Name(), []byte(dcmap), 0644)
+	if err != nil {
+		t.Error("Failed to create test dc_map file.")
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.Int("parallel", 2, "parallel")
+	set.Bool("retry-failed", true, "retry-failed")
+	err = set.Parse([]string{f.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	deployments := photon.Deployments{
+		Items: []photon.Deployment{{ID: "deployment-ID"}},
+	}
+	deploymentsResponse, err := json.Marshal(deployments)
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	queuedTask := &photon.Task{
+		Operation: "CREATE_HOST",
+		State:     "QUEUED",
+		ID:        "fake-failed-host-task-id",
+		Entity:    photon.Entity{ID: "fake-host-id"},
+	}
+	queuedResponse, err := json.Marshal(queuedTask)
+	if err != nil {
+		t.Error("Not expecting error serializing expected queuedTask")
+	}
+	failedTask := &photon.Task{
+		Operation: "CREATE_HOST",
+		State:     "ERROR",
+		ID:        "fake-failed-host-task-id",
+		Entity:    photon.Entity{ID: "fake-host-id"},
+	}
+	failedResponse, err := json.Marshal(failedTask)
+	if err != nil {
+		t.Error("Not expecting error serializing expected failedTask")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/deployments/deployment-ID/hosts",
+		mocks.CreateResponder(200, string(queuedResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+failedTask.ID,
+		mocks.CreateResponder(200, string(failedResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	err = addHosts(cxt)
+	if err == nil || err.Error() != "3 of 3 hosts failed to be created" {
+		t.Errorf("Expected error reporting failed hosts, got '%v'", err)
+	}
 }
 
 func TestDestroy(t *testing.T) {
@@ -491,11 +802,169 @@ func TestDestroy(t *testing.T) {
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
 
 	set := flag.NewFlagSet("test", 0)
+	set.Bool("dry-run", true, "dry-run")
 	cxt := cli.NewContext(nil, set, nil)
 	err = destroy(cxt)
 	if err != nil {
 		t.Error(err)
 	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("deployment", "3", "deployment")
+	set.Bool("yes", true, "yes")
+	cxt = cli.NewContext(nil, set, nil)
+	err = destroy(cxt)
+	if err == nil {
+		t.Error("Expected error destroying unknown deployment")
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.Bool("yes", true, "yes")
+	cxt = cli.NewContext(nil, set, nil)
+	err = destroy(cxt)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Replaces stdin with the given input while running f
+func withStdin(t *testing.T, input string, f func()) {
+	file, err := ioutil.TempFile("", "stdin")
+	if err != nil {
+		t.Fatal("Not expecting error creating stdin file")
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	_, err = file.WriteString(input)
+	if err == nil {
+		_, err = file.Seek(0, 0)
+	}
+	if err != nil {
+		t.Fatal("Not expecting error writing stdin file")
+	}
+
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() {
+		os.Stdin = stdin
+	}()
+	f()
+}
+
+func TestDestroyDeploymentScope(t *testing.T) {
+	server := mocks.NewTestServer()
+	defer server.Close()
+
+	deploymentsResponse, err := json.Marshal(photon.Deployments{
+		Items: []photon.Deployment{{ID: "scope-1", State: "READY"}, {ID: "scope-2", State: "READY"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing deployments")
+	}
+	hosts1Response, err := json.Marshal(MockHostsPage{Items: []photon.Host{
+		{ID: "scope-host-a", Address: "10.0.0.1", AvailabilityZone: "scope-zone-a"},
+		{ID: "scope-host-b", Address: "10.0.0.2", AvailabilityZone: "scope-zone-b"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	hosts2Response, err := json.Marshal(MockHostsPage{Items: []photon.Host{
+		{ID: "scope-host-c", Address: "10.0.0.3", AvailabilityZone: "scope-zone-b"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing hosts")
+	}
+	zonesResponse, err := json.Marshal(photon.AvailabilityZones{Items: []photon.AvailabilityZone{
+		{ID: "scope-zone-a", Name: "zone-a"},
+		{ID: "scope-zone-b", Name: "zone-b"},
+		{ID: "scope-zone-c", Name: "zone-c"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing availability zones")
+	}
+
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments",
+		mocks.CreateResponder(200, string(deploymentsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/scope-1/hosts",
+		mocks.CreateResponder(200, string(hosts1Response[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/deployments/scope-2/hosts",
+		mocks.CreateResponder(200, string(hosts2Response[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/availabilityzones",
+		mocks.CreateResponder(200, string(zonesResponse[:])))
+
+	// Records the calls that change something, in order
+	var calls []string
+	register := func(method string, path string, task photon.Task) {
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			method,
+			server.URL+path,
+			func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, method+" "+path)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+	register("POST", "/deployments/scope-1/destroy",
+		photon.Task{ID: "scope-destroy-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-1"}})
+	register("DELETE", "/hosts/scope-host-a",
+		photon.Task{ID: "scope-host-a-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-host-a"}})
+	register("DELETE", "/hosts/scope-host-b",
+		photon.Task{ID: "scope-host-b-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-host-b"}})
+	register("DELETE", "/availabilityzones/scope-zone-a",
+		photon.Task{ID: "scope-zone-a-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-zone-a"}})
+	register("DELETE", "/availabilityzones/scope-zone-b",
+		photon.Task{ID: "scope-zone-b-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-zone-b"}})
+	register("DELETE", "/deployments/scope-1",
+		photon.Task{ID: "scope-delete-task", State: "COMPLETED", Entity: photon.Entity{ID: "scope-1"}})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("deployment", "scope-1", "deployment")
+	cxt := cli.NewContext(nil, set, nil)
+
+	withStdin(t, "scope-2\n", func() {
+		err = destroy(cxt)
+	})
+	if err == nil {
+		t.Error("Expected a mismatched confirmation to fail")
+	}
+	if len(calls) != 0 {
+		t.Errorf("Expected nothing to be destroyed without confirmation, got %v", calls)
+	}
+
+	withStdin(t, "scope-1\n", func() {
+		err = destroy(cxt)
+	})
+	if err != nil {
+		t.Error("Not expecting destroying the deployment to fail: ", err)
+	}
+	expected := []string{
+		"POST /deployments/scope-1/destroy",
+		"DELETE /hosts/scope-host-a",
+		"DELETE /hosts/scope-host-b",
+		"DELETE /availabilityzones/scope-zone-a",
+		"DELETE /deployments/scope-1",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected the zone used by the other deployment to be kept, got %v", calls)
+	}
 }
 
 func TestInitializeMigrateDeployment(t *testing.T) {
@@ -646,3 +1115,72 @@ func createTaskResponses(op string, entityId string) (string, string, string, er
 
 	return task.ID, string(queued[:]), string(completed[:]), nil
 }
+
+func TestParseIpRanges(t *testing.T) {
+	validRanges := map[string][]string{
+		"10.0.0.1":                        {"10.0.0.1"},
+		"10.0.0.1 - 10.0.0.3":             {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		"10.0.0.1-10.0.0.2, 10.0.1.1":     {"10.0.0.1", "10.0.0.2", "10.0.1.1"},
+		"10.0.0.0/29":                     {"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"},
+		"10.0.0.0/30, !10.0.0.2":          {"10.0.0.1"},
+		"10.0.0.0/29, !10.0.0.2-10.0.0.5": {"10.0.0.1", "10.0.0.6"},
+		"10.0.0.4/32":                     {"10.0.0.4"},
+		"10.0.0.255-10.0.1.0":             {"10.0.0.255", "10.0.1.0"},
+		"fd00::1-fd00::3":                 {"fd00::1", "fd00::2", "fd00::3"},
+		"fd00::/126, !fd00::0":            {"fd00::1", "fd00::2", "fd00::3"},
+	}
+	for ipRanges, expected := range validRanges {
+		ips, err := parseIpRanges(ipRanges)
+		if err != nil {
+			t.Errorf("Not expecting error parsing '%s': %s", ipRanges, err)
+			continue
+		}
+		if fmt.Sprint(ips) != fmt.Sprint(expected) {
+			t.Errorf("Parsing '%s' returned %v, expected %v", ipRanges, ips, expected)
+		}
+	}
+
+	invalidRanges := []string{
+		"",
+		"10.0.0.300",
+		"10.0.0.3-10.0.0.1",
+		"10.0.0.1-fd00::1",
+		"10.0.0.1-10.0.0.2-10.0.0.3",
+		"10.0.0.0/33",
+		"10.0.0.0/8",
+		"fd00::/64",
+	}
+	for _, ipRanges := range invalidRanges {
+		_, err := parseIpRanges(ipRanges)
+		if err == nil {
+			t.Errorf("Expected error parsing '%s'", ipRanges)
+		}
+	}
+}
+
+func TestCompressIpRanges(t *testing.T) {
+	ranges := map[string][]string{
+		"10.0.0.1":                                       {"10.0.0.1"},
+		"10.0.0.1-10.0.0.3":                              {"10.0.0.3", "10.0.0.1", "10.0.0.2"},
+		"10.0.0.1-10.0.0.2, 10.0.1.1":                    {"10.0.1.1", "10.0.0.2", "10.0.0.1"},
+		"10.0.0.255-10.0.1.0":                            {"10.0.0.255", "10.0.1.0"},
+		"10.0.0.1, 10.0.0.3":                             {"10.0.0.1", "10.0.0.3", "10.0.0.3"},
+		"10.0.0.9, fd00::1-fd00::2":                      {"fd00::2", "10.0.0.9", "fd00::1"},
+		"10.0.0.1-10.0.0.2, 10.0.0.4-10.0.0.5, 10.0.0.7": {"10.0.0.7", "10.0.0.5", "10.0.0.4", "10.0.0.2", "10.0.0.1"},
+	}
+	for expected, ips := range ranges {
+		ipRanges, err := compressIpRanges(ips)
+		if err != nil {
+			t.Errorf("Not expecting error compressing %v: %s", ips, err)
+			continue
+		}
+		if ipRanges != expected {
+			t.Errorf("Compressing %v returned '%s', expected '%s'", ips, ipRanges, expected)
+		}
+	}
+
+	_, err := compressIpRanges([]string{"10.0.0.300"})
+	if err == nil {
+		t.Error("Expected error compressing an invalid address")
+	}
+}
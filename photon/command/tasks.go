@@ -12,6 +12,7 @@ package command
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -42,7 +43,7 @@ func GetTasksCommand() cli.Command {
 			{
 				Name:  "list",
 				Usage: "list all tasks",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "entityId, e",
 						Usage: "specify entity ID for filtering",
@@ -55,7 +56,7 @@ func GetTasksCommand() cli.Command {
 						Name:  "state, s",
 						Usage: "specify task state for filtering",
 					},
-				},
+				}, pagingFlags()...),
 				Action: func(c *cli.Context) {
 					err := listTasks(c)
 					if err != nil {
@@ -97,26 +98,46 @@ func listTasks(c *cli.Context) error {
 	entityId := c.String("entityId")
 	entityKind := c.String("entityKind")
 	state := c.String("state")
+	paging, err := getListPaging(c)
+	if err != nil {
+		return err
+	}
 
 	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
 
-	options := &photon.TaskGetOptions{
-		State:      state,
-		EntityID:   entityId,
-		EntityKind: entityKind,
+	query := url.Values{}
+	if len(state) != 0 {
+		query.Set("state", state)
+	}
+	if len(entityId) != 0 {
+		query.Set("entityId", entityId)
 	}
-	taskList, err := client.Esxclient.Tasks.GetAll(options)
+	if len(entityKind) != 0 {
+		query.Set("entityKind", entityKind)
+	}
+
+	printer := newTaskListPrinter(os.Stdout, c)
+	nextPageToken, err := streamList("/tasks", query, paging, func(items []json.RawMessage) error {
+		tasks := make([]photon.Task, len(items))
+		for i, item := range items {
+			err := json.Unmarshal(item, &tasks[i])
+			if err != nil {
+				return err
+			}
+		}
+		return printer.printPage(tasks)
+	})
 	if err != nil {
 		return err
 	}
-
-	err = printTaskList(taskList.Items, c)
+	err = printer.done()
 	if err != nil {
 		return err
 	}
+	printNextPageToken(nextPageToken, os.Stdout, c)
 	return nil
 }
 
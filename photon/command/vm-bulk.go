@@ -0,0 +1,357 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// The power and lifecycle operations that can run on several VMs at once
+var vmOperations = map[string]func(id string) (*photon.Task, error){
+	"start":   func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Start(id) },
+	"stop":    func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Stop(id) },
+	"suspend": func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Suspend(id) },
+	"resume":  func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Resume(id) },
+	"restart": func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Restart(id) },
+	"delete":  func(id string) (*photon.Task, error) { return client.Esxclient.VMs.Delete(id) },
+}
+
+// Selects VMs by their properties, empty fields match every VM
+type vmFilter struct {
+	nameRegex *regexp.Regexp
+	tags      []string
+	metadata  map[string]string
+	state     string
+	host      string
+	flavor    string
+	image     string
+}
+
+// Outcome of an operation on one VM
+type vmOperationResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Flags selecting VMs by their properties
+func vmFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "name-regex",
+			Usage: "VMs with a name matching the regular expression",
+		},
+		cli.StringSliceFlag{
+			Name:  "tag",
+			Value: &cli.StringSlice{},
+			Usage: "VMs with the tag, can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "metadata",
+			Value: &cli.StringSlice{},
+			Usage: "VMs with the metadata key=value, can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "state",
+			Usage: "VMs in the state, e.g. STARTED",
+		},
+		cli.StringFlag{
+			Name:  "host",
+			Usage: "VMs on the host",
+		},
+		cli.StringFlag{
+			Name:  "flavor",
+			Usage: "VMs of the flavor",
+		},
+		cli.StringFlag{
+			Name:  "image",
+			Usage: "VMs created from the image, name or ID",
+		},
+	}
+}
+
+// Flags of the VM operations that select the VMs to run on
+func vmSelectorFlags() []cli.Flag {
+	return append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "All the VMs of the project",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Value: 10,
+			Usage: "Number of VMs processed at the same time",
+		},
+		cli.StringFlag{
+			Name:  "tenant, t",
+			Usage: "Tenant name",
+		},
+		cli.StringFlag{
+			Name:  "project, p",
+			Usage: "Project name",
+		},
+	}, vmFilterFlags()...)
+}
+
+func getVMFilter(c *cli.Context) (*vmFilter, error) {
+	filter := &vmFilter{
+		tags:   c.StringSlice("tag"),
+		state:  strings.ToUpper(c.String("state")),
+		host:   c.String("host"),
+		flavor: c.String("flavor"),
+		image:  c.String("image"),
+	}
+	if len(c.String("name-regex")) != 0 {
+		var err error
+		filter.nameRegex, err = regexp.Compile(c.String("name-regex"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --name-regex: %s", err)
+		}
+	}
+	for _, metadata := range c.StringSlice("metadata") {
+		parts := strings.SplitN(metadata, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("Invalid --metadata '%s', should be <key>=<value>", metadata)
+		}
+		if filter.metadata == nil {
+			filter.metadata = make(map[string]string)
+		}
+		filter.metadata[parts[0]] = parts[1]
+	}
+	return filter, nil
+}
+
+// Resolves the image name of the filter, once the client is set up
+func (filter *vmFilter) resolve() error {
+	if len(filter.image) == 0 {
+		return nil
+	}
+	var err error
+	filter.image, err = resolveImageID(filter.image)
+	return err
+}
+
+func (filter *vmFilter) isEmpty() bool {
+	return filter.nameRegex == nil && len(filter.tags) == 0 && len(filter.metadata) == 0 &&
+		len(filter.state) == 0 && len(filter.host) == 0 && len(filter.flavor) == 0 && len(filter.image) == 0
+}
+
+func (filter *vmFilter) matches(vm photon.VM) bool {
+	if filter.nameRegex != nil && !filter.nameRegex.MatchString(vm.Name) {
+		return false
+	}
+	for _, tag := range filter.tags {
+		if !contains(vm.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range filter.metadata {
+		if actual, ok := vm.Metadata[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return (len(filter.state) == 0 || vm.State == filter.state) &&
+		(len(filter.host) == 0 || vm.Host == filter.host) &&
+		(len(filter.flavor) == 0 || vm.Flavor == filter.flavor) &&
+		(len(filter.image) == 0 || vm.SourceImageID == filter.image)
+}
+
+// Runs a power or lifecycle operation on one VM as before, or on several VMs given by ID or
+// selected with --all and the selector flags. Several VMs are processed at most --parallel at
+// a time after a single confirmation, and the outcome for each VM is printed.
+func runVMOperation(c *cli.Context, operation string, w io.Writer) error {
+	usage := fmt.Sprintf("vm %s <id> [<id>...] [<options>]", operation)
+	filter, err := getVMFilter(c)
+	if err != nil {
+		return err
+	}
+	selecting := c.Bool("all") || !filter.isEmpty()
+	if selecting && len(c.Args()) != 0 {
+		return errors.New("Give either VM IDs or --all and selectors, not both")
+	}
+	if !selecting && len(c.Args()) == 0 {
+		return checkArgNum(c.Args(), 1, usage)
+	}
+	isScripting := utils.IsNonInteractive(c)
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	var resolve func(string) (string, error)
+	if !selecting {
+		resolve, err = getVMResolver(c)
+		if err != nil {
+			return err
+		}
+	}
+	if !selecting && len(c.Args()) == 1 {
+		id, err := resolve(c.Args().First())
+		if err != nil {
+			return err
+		}
+		task, err := vmOperations[operation](id)
+		if err != nil {
+			return err
+		}
+		_, err = waitOnTaskOperation(task.ID, c)
+		return err
+	}
+
+	err = filter.resolve()
+	if err != nil {
+		return err
+	}
+	vms, err := selectVMs(c, filter, selecting, resolve)
+	if err != nil {
+		return err
+	}
+	if len(vms) == 0 {
+		return errors.New("No VM matches the selection")
+	}
+
+	if !isScripting {
+		fmt.Fprintf(w, "%d VMs will be %s:\n", len(vms), vmOperationPastTense(operation))
+		for _, vm := range vms {
+			fmt.Fprintf(w, "  %s (%s)\n", vm.Name, vm.ID)
+		}
+	}
+	if !confirmed(isScripting) {
+		fmt.Fprintln(w, "OK. Canceled")
+		return nil
+	}
+
+	results := make([]vmOperationResult, len(vms))
+	errs := runInParallel(len(vms), c.Int("parallel"), strings.ToUpper(operation)+"_VM", isScripting, func(i int) error {
+		return waitForTask(vmOperations[operation](vms[i].ID))
+	})
+	failed := 0
+	for i, vm := range vms {
+		results[i] = vmOperationResult{ID: vm.ID, Name: vm.Name, State: vm.State, Result: "done"}
+		if errs[i] != nil {
+			results[i].Result = "failed"
+			results[i].Error = errs[i].Error()
+			failed++
+		}
+	}
+
+	err = printVMOperationResults(results, w, c)
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d VMs failed to %s", failed, len(vms), operation)
+	}
+	return nil
+}
+
+// Returns the VMs of the project matching the filter, or the VMs given as arguments
+func selectVMs(c *cli.Context, filter *vmFilter, selecting bool, resolve func(string) (string, error)) ([]photon.VM, error) {
+	var vms []photon.VM
+	if !selecting {
+		for _, arg := range c.Args() {
+			id, err := resolve(arg)
+			if err != nil {
+				return nil, err
+			}
+			vm, err := client.Esxclient.VMs.Get(id)
+			if err != nil {
+				return nil, err
+			}
+			vms = append(vms, *vm)
+		}
+		return vms, nil
+	}
+
+	tenant, err := verifyTenant(c.String("tenant"))
+	if err != nil {
+		return nil, err
+	}
+	project, err := verifyProject(tenant.ID, c.String("project"))
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.Esxclient.Projects.GetVMs(project.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range list.Items {
+		if filter.matches(vm) {
+			vms = append(vms, vm)
+		}
+	}
+	return vms, nil
+}
+
+// Returns how VM names are resolved: in the project given with --tenant or --project, or in
+// the current project when neither is set
+func getVMResolver(c *cli.Context) (func(string) (string, error), error) {
+	if len(c.String("tenant")) == 0 && len(c.String("project")) == 0 {
+		return resolveVMID, nil
+	}
+	tenant, err := verifyTenant(c.String("tenant"))
+	if err != nil {
+		return nil, err
+	}
+	project, err := verifyProject(tenant.ID, c.String("project"))
+	if err != nil {
+		return nil, err
+	}
+	return func(nameOrID string) (string, error) {
+		return resolveProjectVMID(project.ID, nameOrID)
+	}, nil
+}
+
+func vmOperationPastTense(operation string) string {
+	switch operation {
+	case "stop":
+		return "stopped"
+	case "delete", "resume":
+		return operation + "d"
+	}
+	return operation + "ed"
+}
+
+func printVMOperationResults(results []vmOperationResult, w io.Writer, c *cli.Context) error {
+	if c.GlobalIsSet("non-interactive") {
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.ID, result.Name, result.State, result.Result, result.Error)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(results, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "VM ID\tName\tState\tResult\tError\n")
+		for _, result := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.ID, result.Name, result.State, result.Result,
+				valueOrDash(result.Error))
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(results))
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestVMFilter(t *testing.T) {
+	vm := photon.VM{
+		Name:     "ci-runner-1",
+		State:    "STARTED",
+		Host:     "10.0.0.1",
+		Flavor:   "core-100",
+		Tags:     []string{"env:ci", "team:build"},
+		Metadata: map[string]string{"owner": "ops"},
+	}
+
+	var tests = []struct {
+		Set     func(set *flag.FlagSet)
+		Matches bool
+	}{
+		{func(set *flag.FlagSet) {}, true},
+		{func(set *flag.FlagSet) { set.String("name-regex", "^ci-", "") }, true},
+		{func(set *flag.FlagSet) { set.String("name-regex", "^web-", "") }, false},
+		{func(set *flag.FlagSet) { set.Var(&cli.StringSlice{"env:ci", "team:build"}, "tag", "") }, true},
+		{func(set *flag.FlagSet) { set.Var(&cli.StringSlice{"env:ci", "team:web"}, "tag", "") }, false},
+		{func(set *flag.FlagSet) { set.Var(&cli.StringSlice{"owner=ops"}, "metadata", "") }, true},
+		{func(set *flag.FlagSet) { set.Var(&cli.StringSlice{"owner=dev"}, "metadata", "") }, false},
+		{func(set *flag.FlagSet) { set.String("state", "started", "") }, true},
+		{func(set *flag.FlagSet) { set.String("state", "STOPPED", "") }, false},
+		{func(set *flag.FlagSet) { set.String("host", "10.0.0.2", "") }, false},
+		{func(set *flag.FlagSet) { set.String("flavor", "core-100", "") }, true},
+	}
+	for i, test := range tests {
+		set := flag.NewFlagSet("test", 0)
+		test.Set(set)
+		filter, err := getVMFilter(cli.NewContext(nil, set, nil))
+		if err != nil {
+			t.Errorf("Not expecting filter %d to fail: %s", i, err)
+			continue
+		}
+		if filter.matches(vm) != test.Matches {
+			t.Errorf("Filter %d matches %v, expected %v", i, !test.Matches, test.Matches)
+		}
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.Var(&cli.StringSlice{"owner"}, "metadata", "")
+	_, err := getVMFilter(cli.NewContext(nil, set, nil))
+	if err == nil {
+		t.Error("Expected metadata without a value to fail")
+	}
+}
+
+func TestStopVMsBySelector(t *testing.T) {
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "fake_project_name", ID: "fake_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	vmsResponse, err := json.Marshal(photon.VMs{Items: []photon.VM{
+		{ID: "bulk-vm-1", Name: "ci-1", State: "STARTED", Tags: []string{"env:ci"}},
+		{ID: "bulk-vm-2", Name: "ci-2", State: "STARTED", Tags: []string{"env:ci"}},
+		{ID: "bulk-vm-3", Name: "ci-3", State: "STOPPED", Tags: []string{"env:ci"}},
+		{ID: "bulk-vm-4", Name: "web-1", State: "STARTED", Tags: []string{"env:prod"}},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=fake_project_name",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/fake_project_ID/vms",
+		mocks.CreateResponder(200, string(vmsResponse[:])))
+
+	var mutex sync.Mutex
+	var stopped []string
+	for _, id := range []string{"bulk-vm-1", "bulk-vm-2", "bulk-vm-3", "bulk-vm-4"} {
+		id := id
+		task := photon.Task{ID: "stop-" + id, State: "COMPLETED", Entity: photon.Entity{ID: id}}
+		if id == "bulk-vm-2" {
+			task.State = "ERROR"
+		}
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/"+id+"/stop",
+			func(req *http.Request) (*http.Response, error) {
+				mutex.Lock()
+				stopped = append(stopped, id)
+				mutex.Unlock()
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.Var(&cli.StringSlice{"env:ci"}, "tag", "tag")
+	set.String("state", "STARTED", "state")
+	set.Int("parallel", 2, "parallel")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = runVMOperation(cxt, "stop", &buf)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 VMs failed to stop") {
+		t.Errorf("Expected one of the VMs to fail, got %v", err)
+	}
+	sort.Strings(stopped)
+	if strings.Join(stopped, ",") != "bulk-vm-1,bulk-vm-2" {
+		t.Errorf("Unexpected stopped VMs: %v", stopped)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "bulk-vm-1\tci-1\tSTARTED\tdone") ||
+		!strings.HasPrefix(lines[1], "bulk-vm-2\tci-2\tSTARTED\tfailed\t") {
+		t.Errorf("Unexpected results:\n%s", buf.String())
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.Bool("all", true, "all")
+	err = set.Parse([]string{"--all", "bulk-vm-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = runVMOperation(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "stop", &buf)
+	if err == nil {
+		t.Error("Expected IDs together with --all to fail")
+	}
+}
+
+func TestStopVMsByNameInProject(t *testing.T) {
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "prod", ID: "prod_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	currentVMsResponse, err := json.Marshal(photon.VMs{Items: []photon.VM{{ID: "dev-web-1", Name: "web-1", State: "STARTED"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+	prodVMsResponse, err := json.Marshal(photon.VMs{Items: []photon.VM{{ID: "prod-web-1", Name: "web-1", State: "STARTED"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=prod",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/dev_project_ID/vms",
+		mocks.CreateResponder(200, string(currentVMsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/prod_project_ID/vms",
+		mocks.CreateResponder(200, string(prodVMsResponse[:])))
+
+	var stopped []string
+	for _, id := range []string{"dev-web-1", "prod-web-1"} {
+		id := id
+		taskResponse, err := json.Marshal(photon.Task{ID: "stop-" + id, State: "COMPLETED", Entity: photon.Entity{ID: id}})
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/"+id+"/stop",
+			func(req *http.Request) (*http.Response, error) {
+				stopped = append(stopped, id)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/stop-"+id,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	configOri, err := cf.LoadConfig()
+	if err != nil {
+		t.Error("Not expecting error loading config file")
+	}
+	err = cf.SaveConfig(&cf.Configuration{
+		Tenant:  &cf.TenantConfiguration{Name: "fake_tenant_name", ID: "fake_tenant_ID"},
+		Project: &cf.ProjectConfiguration{Name: "dev", ID: "dev_project_ID"},
+	})
+	if err != nil {
+		t.Error("Not expecting error when saving config file")
+	}
+	defer cf.SaveConfig(configOri)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("project", "prod", "project")
+	err = set.Parse([]string{"web-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var buf bytes.Buffer
+	err = runVMOperation(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "stop", &buf)
+	if err != nil {
+		t.Error("Not expecting stopping the VM to fail: ", err)
+	}
+	if strings.Join(stopped, ",") != "prod-web-1" {
+		t.Errorf("Expected web-1 of the given project to be stopped, got %v", stopped)
+	}
+
+	stopped = nil
+	set = flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{"web-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = runVMOperation(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "stop", &buf)
+	if err != nil {
+		t.Error("Not expecting stopping the VM to fail: ", err)
+	}
+	if strings.Join(stopped, ",") != "dev-web-1" {
+		t.Errorf("Expected web-1 of the current project to be stopped, got %v", stopped)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// Creates copies of a VM from an intermediate image of it. The copies get the flavor, the
+// ephemeral disks, the networks, the tags and the metadata of the source VM. Persistent disks
+// attached to the source VM are not copied. The source VM is looked up in the project the
+// copies are created in.
+func cloneVM(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "vm clone <id> [<options>]")
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	name := c.String("name")
+	if len(name) == 0 {
+		return errors.New("Please provide the name of the new VMs with --name")
+	}
+	count := c.Int("count")
+	if count < 1 {
+		return errors.New("--count must be at least 1")
+	}
+	names, err := getVMNames(name, count, c.String("name-template"))
+	if err != nil {
+		return err
+	}
+	isScripting := utils.IsNonInteractive(c)
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	tenant, err := verifyTenant(c.String("tenant"))
+	if err != nil {
+		return err
+	}
+	project, err := verifyProject(tenant.ID, c.String("project"))
+	if err != nil {
+		return err
+	}
+
+	id, err = resolveProjectVMID(project.ID, id)
+	if err != nil {
+		return err
+	}
+	source, err := client.Esxclient.VMs.Get(id)
+	if err != nil {
+		return err
+	}
+	networks, err := getCloneNetworks(id, c.String("networks"))
+	if err != nil {
+		return err
+	}
+	vmSpec, skipped := getCloneCreateSpec(source, networks)
+
+	imageName := c.String("image-name")
+	if len(imageName) == 0 {
+		imageName = "clone-of-" + source.Name
+	}
+
+	if !isScripting {
+		fmt.Fprintf(w, "\nCloning VM %s (%s) into %d VMs (%s) through image %s:\n",
+			source.Name, source.ID, len(names), vmSpec.Flavor, imageName)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+		for _, disk := range skipped {
+			fmt.Fprintf(w, "Persistent disk %s (%s) is not cloned\n", disk.Name, disk.ID)
+		}
+	}
+	if !confirmed(isScripting) {
+		fmt.Fprintln(w, "OK. Canceled")
+		return nil
+	}
+
+	imageTask, err := client.Esxclient.VMs.CreateImage(id, &photon.ImageCreateSpec{
+		Name:            imageName,
+		ReplicationType: c.String("image-replication"),
+	})
+	if err != nil {
+		return err
+	}
+	imageTask, err = client.Esxclient.Tasks.Wait(imageTask.ID)
+	if err != nil {
+		return fmt.Errorf("Creating the image of VM %s failed: %s", source.Name, err)
+	}
+	vmSpec.SourceImageID = imageTask.Entity.ID
+
+	err = createClones(project.ID, vmSpec, names, source.Metadata, c)
+	if c.Bool("keep-image") {
+		if !isScripting {
+			fmt.Fprintf(w, "Image %s (%s) is kept\n", imageName, vmSpec.SourceImageID)
+		}
+		return err
+	}
+	deleteErr := waitForTask(client.Esxclient.Images.Delete(vmSpec.SourceImageID))
+	if err != nil {
+		return err
+	}
+	if deleteErr != nil {
+		return fmt.Errorf("Deleting image %s failed: %s", vmSpec.SourceImageID, deleteErr)
+	}
+	return nil
+}
+
+// Creates the VMs one after the other, stopping at the first one that fails
+func createClones(projectID string, vmSpec *photon.VmCreateSpec, names []string, metadata map[string]string,
+	c *cli.Context) error {
+	for _, name := range names {
+		vmSpec.Name = name
+		createTask, err := client.Esxclient.Projects.CreateVM(projectID, vmSpec)
+		if err != nil {
+			return fmt.Errorf("Creating VM %s failed: %s", name, err)
+		}
+		id, err := waitOnTaskOperation(createTask.ID, c)
+		if err != nil {
+			return fmt.Errorf("Creating VM %s failed: %s", name, err)
+		}
+		if len(metadata) != 0 {
+			err = waitForTask(client.Esxclient.VMs.SetMetadata(id, &photon.VmMetadata{Metadata: metadata}))
+			if err != nil {
+				return fmt.Errorf("Setting the metadata of VM %s failed: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Builds the create spec of the clones from the source VM. The boot disk comes from the image,
+// so only its name and flavor are kept. Returns the persistent disks left out of the spec.
+func getCloneCreateSpec(source *photon.VM, networks []string) (*photon.VmCreateSpec, []photon.AttachedDisk) {
+	vmSpec := &photon.VmCreateSpec{
+		Flavor:   source.Flavor,
+		Tags:     source.Tags,
+		Networks: networks,
+	}
+	var skipped []photon.AttachedDisk
+	for _, disk := range source.AttachedDisks {
+		if disk.Kind == "persistent-disk" {
+			skipped = append(skipped, disk)
+			continue
+		}
+		clone := photon.AttachedDisk{Name: disk.Name, Flavor: disk.Flavor, Kind: disk.Kind, BootDisk: disk.BootDisk}
+		if !disk.BootDisk {
+			clone.CapacityGB = disk.CapacityGB
+		}
+		vmSpec.AttachedDisks = append(vmSpec.AttachedDisks, clone)
+	}
+	return vmSpec, skipped
+}
+
+// Returns the IDs of the networks given with --networks, or of the networks the source VM is
+// connected to. The VM reports the port groups of its networks, which are matched against the
+// names, IDs and port groups of the networks.
+func getCloneNetworks(id string, networksFlag string) ([]string, error) {
+	var names []string
+	if len(networksFlag) != 0 {
+		names = regexp.MustCompile(`\s*,\s*`).Split(networksFlag, -1)
+		for i, name := range names {
+			var err error
+			names[i], err = resolveNetworkID(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return names, nil
+	}
+
+	connections, err := getVMNetworks(id, true)
+	if err != nil {
+		return nil, fmt.Errorf("Getting the networks of VM %s failed, use --networks instead: %s", id, err)
+	}
+	for _, nt := range connections {
+		network := nt.(map[string]interface{})
+		if name, ok := network["network"].(string); ok && len(name) != 0 && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	all, err := client.Esxclient.Networks.GetAll(nil)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, name := range names {
+		found := false
+		for _, network := range all.Items {
+			if network.ID == name || network.Name == name || contains(network.PortGroups, name) {
+				if !contains(ids, network.ID) {
+					ids = append(ids, network.ID)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("No network has the port group %s of VM %s, use --networks instead", name, id)
+		}
+	}
+	return ids, nil
+}
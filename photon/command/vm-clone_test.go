@@ -0,0 +1,233 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestCloneVM(t *testing.T) {
+	sourceID := "7c1d3e5f-0a2b-4c6d-8e9f-1a2b3c4d5e6f"
+	source := photon.VM{
+		ID:     sourceID,
+		Name:   "web",
+		State:  "STARTED",
+		Flavor: "core-100",
+		Tags:   []string{"env:prod"},
+		AttachedDisks: []photon.AttachedDisk{
+			{ID: "disk-1", Name: "boot", Flavor: "core-100", Kind: "ephemeral-disk", CapacityGB: 2, BootDisk: true},
+			{ID: "disk-2", Name: "scratch", Flavor: "core-100", Kind: "ephemeral-disk", CapacityGB: 10},
+			{ID: "disk-3", Name: "data", Flavor: "core-100", Kind: "persistent-disk", CapacityGB: 50},
+		},
+		Metadata: map[string]string{"owner": "ops"},
+	}
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "fake_project_name", ID: "fake_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	sourceResponse, err := json.Marshal(source)
+	if err != nil {
+		t.Error("Not expecting error serializing VM")
+	}
+	projectVMsResponse, err := json.Marshal(photon.VMs{Items: []photon.VM{source}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+	networksTask := photon.Task{ID: "clone-networks-task", State: "COMPLETED",
+		ResourceProperties: map[string]interface{}{"networkConnections": []interface{}{
+			map[string]interface{}{"network": "VM Network", "ipAddress": "10.0.0.5"},
+			map[string]interface{}{"network": nil, "ipAddress": "127.0.0.1"},
+		}}}
+	networksTaskResponse, err := json.Marshal(networksTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	networksResponse, err := json.Marshal(photon.Networks{Items: []photon.Network{
+		{ID: "network-1", Name: "management", PortGroups: []string{"Management Network"}},
+		{ID: "network-2", Name: "vms", PortGroups: []string{"VM Network"}},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing networks")
+	}
+	imageTask := photon.Task{ID: "clone-image-task", State: "COMPLETED", Entity: photon.Entity{ID: "clone-image"}}
+	imageTaskResponse, err := json.Marshal(imageTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=fake_project_name",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/fake_project_ID/vms",
+		mocks.CreateResponder(200, string(projectVMsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+sourceID,
+		mocks.CreateResponder(200, string(sourceResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+sourceID+"/networks",
+		mocks.CreateResponder(200, string(networksTaskResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+networksTask.ID,
+		mocks.CreateResponder(200, string(networksTaskResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/networks",
+		mocks.CreateResponder(200, string(networksResponse[:])))
+
+	var imageSpec photon.ImageCreateSpec
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/vms/"+sourceID+"/create_image",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &imageSpec)
+			return mocks.CreateResponder(200, string(imageTaskResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+imageTask.ID,
+		mocks.CreateResponder(200, string(imageTaskResponse[:])))
+
+	var specs []photon.VmCreateSpec
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/projects/fake_project_ID/vms",
+		func(req *http.Request) (*http.Response, error) {
+			var spec photon.VmCreateSpec
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &spec)
+			specs = append(specs, spec)
+			task := photon.Task{ID: "clone-create-" + spec.Name, State: "COMPLETED", Entity: photon.Entity{ID: "clone-" + spec.Name}}
+			response, _ := json.Marshal(task)
+			mocks.RegisterResponder(
+				"GET",
+				server.URL+"/tasks/"+task.ID,
+				mocks.CreateResponder(200, string(response[:])))
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+	var metadataSet []string
+	for _, name := range []string{"web-copy-1", "web-copy-2"} {
+		id := "clone-" + name
+		task := photon.Task{ID: "clone-metadata-" + name, State: "COMPLETED", Entity: photon.Entity{ID: id}}
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			server.URL+"/vms/"+id+"/set_metadata",
+			func(req *http.Request) (*http.Response, error) {
+				metadataSet = append(metadataSet, id)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+	deleteTask := photon.Task{ID: "clone-delete-image", State: "COMPLETED", Entity: photon.Entity{ID: "clone-image"}}
+	deleteTaskResponse, err := json.Marshal(deleteTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	imageDeleted := false
+	mocks.RegisterResponder(
+		"DELETE",
+		server.URL+"/images/clone-image",
+		func(req *http.Request) (*http.Response, error) {
+			imageDeleted = true
+			return mocks.CreateResponder(200, string(deleteTaskResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+deleteTask.ID,
+		mocks.CreateResponder(200, string(deleteTaskResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "web-copy", "name")
+	set.Int("count", 2, "count")
+	set.String("image-replication", "EAGER", "image-replication")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	err = set.Parse([]string{"web"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = cloneVM(cxt, &buf)
+	if err != nil {
+		t.Fatal("Not expecting cloning the VM to fail: ", err)
+	}
+	if imageSpec.Name != "clone-of-web" || imageSpec.ReplicationType != "EAGER" {
+		t.Errorf("Unexpected image spec %+v", imageSpec)
+	}
+	if len(specs) != 2 || specs[0].Name != "web-copy-1" || specs[1].Name != "web-copy-2" {
+		t.Fatalf("Expected VMs web-copy-1 and web-copy-2 to be created, got %+v", specs)
+	}
+	expectedDisks := []photon.AttachedDisk{
+		{Name: "boot", Flavor: "core-100", Kind: "ephemeral-disk", BootDisk: true},
+		{Name: "scratch", Flavor: "core-100", Kind: "ephemeral-disk", CapacityGB: 10},
+	}
+	spec := specs[0]
+	if spec.Flavor != "core-100" || spec.SourceImageID != "clone-image" ||
+		!reflect.DeepEqual(spec.AttachedDisks, expectedDisks) ||
+		!reflect.DeepEqual(spec.Networks, []string{"network-2"}) ||
+		!reflect.DeepEqual(spec.Tags, []string{"env:prod"}) {
+		t.Errorf("Unexpected create spec %+v", spec)
+	}
+	if strings.Join(metadataSet, ",") != "clone-web-copy-1,clone-web-copy-2" {
+		t.Errorf("Expected the metadata of both VMs to be set, got %v", metadataSet)
+	}
+	if !imageDeleted {
+		t.Error("Expected the intermediate image to be deleted")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// The local ssh client
+var sshCommand = "ssh"
+
+// How to reach the console of a VM
+type vmConsole struct {
+	VMID          string `json:"vmId"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Ticket        string `json:"ticket"`
+	SSLThumbprint string `json:"sslThumbprint,omitempty"`
+	WebMKSURL     string `json:"webmksUrl"`
+	URL           string `json:"url"`
+}
+
+// Runs the local ssh client against the first connected IP address of a VM
+func sshVM(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return checkArgNum(c.Args(), 1, "vm ssh [<options>] <id> [-- <command>]")
+	}
+	id := c.Args().First()
+
+	var err error
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
+	networks, err := getVMNetworks(id, true)
+	if err != nil {
+		return err
+	}
+	ip := getVMIPAddress(networks)
+	if len(ip) == 0 {
+		return fmt.Errorf("VM %s has no connected network with an IP address", id)
+	}
+
+	cmd := exec.Command(sshCommand, getSSHArgs(ip, c.String("user"), c.String("identity"), c.Int("port"), c.Args().Tail())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Returns the exit status of a command that ran and failed, so that 'vm ssh' exits like ssh did
+func getExitStatus(err error) (int, bool) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0, false
+	}
+	return status.ExitStatus(), true
+}
+
+func getSSHArgs(ip string, user string, identity string, port int, command []string) []string {
+	var args []string
+	if len(identity) != 0 {
+		args = append(args, "-i", expandHomeDir(identity))
+	}
+	if port != 0 {
+		args = append(args, "-p", strconv.Itoa(port))
+	}
+	destination := ip
+	if len(user) != 0 {
+		destination = user + "@" + ip
+	}
+	return append(append(args, destination), command...)
+}
+
+// Prints the console URL of a VM from a new MKS ticket, and opens it with --open
+func getVMConsole(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "vm console <id> [<options>]")
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	var urlTemplate *template.Template
+	if len(c.String("url-template")) != 0 {
+		urlTemplate, err = template.New("url").Option("missingkey=error").Parse(c.String("url-template"))
+		if err != nil {
+			return fmt.Errorf("Invalid --url-template: %s", err)
+		}
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
+	task, err := client.Esxclient.VMs.GetMKSTicket(id)
+	if err != nil {
+		return err
+	}
+	task, err = client.Esxclient.Tasks.Wait(task.ID)
+	if err != nil {
+		return err
+	}
+	ticket, _ := task.ResourceProperties.(map[string]interface{})
+	console, err := newVMConsole(id, ticket, urlTemplate)
+	if err != nil {
+		return err
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintln(w, console.URL)
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObject(console, w, c)
+	} else {
+		fmt.Fprintf(w, "Console URL: %s\n", console.URL)
+		fmt.Fprintf(w, "WebMKS URL:  %s\n", console.WebMKSURL)
+	}
+
+	if c.Bool("open") {
+		return openURL(console.URL)
+	}
+	return nil
+}
+
+// Builds the console URLs from the properties of an MKS ticket. Without a template the URL
+// opens the VMware Remote Console.
+func newVMConsole(id string, ticket map[string]interface{}, urlTemplate *template.Template) (*vmConsole, error) {
+	console := &vmConsole{VMID: id}
+	console.Host, _ = ticket["host"].(string)
+	console.Ticket, _ = ticket["ticket"].(string)
+	console.SSLThumbprint, _ = ticket["sslThumbprint"].(string)
+	if port, ok := ticket["port"].(float64); ok {
+		console.Port = int(port)
+	}
+	if len(console.Host) == 0 || len(console.Ticket) == 0 {
+		return nil, fmt.Errorf("The MKS ticket of VM %s has no host or ticket", id)
+	}
+	if console.Port == 0 {
+		console.Port = 443
+	}
+
+	address := net.JoinHostPort(console.Host, strconv.Itoa(console.Port))
+	console.WebMKSURL = "wss://" + address + "/ticket/" + escapeURLSegment(console.Ticket)
+	if urlTemplate == nil {
+		console.URL = "vmrc://clone:" + escapeURLSegment(console.Ticket) + "@" + address + "/?moid=" + url.QueryEscape(id)
+		return console, nil
+	}
+	var buf bytes.Buffer
+	err := urlTemplate.Execute(&buf, console)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --url-template: %s", err)
+	}
+	console.URL = buf.String()
+	return console, nil
+}
+
+// Opens a URL with the default handler of the desktop
+func openURL(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
+
+// Escapes a ticket for a path or the user of a URL, like url.PathEscape which needs Go 1.8
+func escapeURLSegment(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
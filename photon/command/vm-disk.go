@@ -0,0 +1,170 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// Flags shared by 'vm attach-disk' and 'vm detach-disk'
+func vmDiskFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{
+			Name:  "stop-if-needed",
+			Usage: "Stop a running VM for the operation and start it again afterwards",
+		},
+		cli.StringFlag{
+			Name:  "tenant, t",
+			Usage: "Tenant name",
+		},
+		cli.StringFlag{
+			Name:  "project, p",
+			Usage: "Project name",
+		},
+	}
+}
+
+// Attaches a persistent disk to a VM or detaches it. The VM and the disk are resolved by name
+// within the project given with --tenant and --project, or the current one. With --create the disk is
+// created first, and with --stop-if-needed a running VM is stopped for the operation.
+func changeVMDisk(c *cli.Context, operation string, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, fmt.Sprintf("vm %s-disk <id> [<options>]", operation))
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	disk := c.String("disk")
+	if len(disk) == 0 {
+		return errors.New("Please provide the disk name or ID with --disk")
+	}
+	create := operation == "attach" && c.Bool("create")
+	if create && (len(c.String("flavor")) == 0 || c.Int("capacityGB") <= 0) {
+		return errors.New("--create needs the --flavor and --capacityGB of the disk")
+	}
+	isScripting := utils.IsNonInteractive(c)
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	var diskID string
+	if create || len(c.String("tenant")) != 0 || len(c.String("project")) != 0 {
+		var tenant *cf.TenantConfiguration
+		tenant, err = verifyTenant(c.String("tenant"))
+		if err != nil {
+			return err
+		}
+		var project *cf.ProjectConfiguration
+		project, err = verifyProject(tenant.ID, c.String("project"))
+		if err != nil {
+			return err
+		}
+		id, err = resolveProjectVMID(project.ID, id)
+		if err != nil {
+			return err
+		}
+		if create {
+			diskID, err = createVMDisk(project.ID, disk, c.String("flavor"), c.Int("capacityGB"))
+			if err != nil {
+				return err
+			}
+			if !isScripting {
+				fmt.Fprintf(w, "Created disk %s (%s)\n", disk, diskID)
+			}
+		} else {
+			diskID, err = resolveProjectDiskID(project.ID, disk)
+		}
+	} else {
+		id, err = resolveVMID(id)
+		if err != nil {
+			return err
+		}
+		diskID, err = resolveDiskID(disk)
+	}
+	if err != nil {
+		return err
+	}
+
+	restart := false
+	if c.Bool("stop-if-needed") {
+		vm, err := client.Esxclient.VMs.Get(id)
+		if err != nil {
+			return err
+		}
+		restart = vm.State == "STARTED"
+	}
+	if restart {
+		err = waitForTask(client.Esxclient.VMs.Stop(id))
+		if err != nil {
+			return fmt.Errorf("Stopping VM %s failed: %s", id, err)
+		}
+		if !isScripting {
+			fmt.Fprintf(w, "Stopped VM %s\n", id)
+		}
+	}
+
+	diskOperation := &photon.VmDiskOperation{DiskID: diskID}
+	var task *photon.Task
+	if operation == "attach" {
+		task, err = client.Esxclient.VMs.AttachDisk(id, diskOperation)
+	} else {
+		task, err = client.Esxclient.VMs.DetachDisk(id, diskOperation)
+	}
+	if err == nil {
+		_, err = waitOnTaskOperation(task.ID, c)
+	}
+	if err != nil && !c.Bool("stop-if-needed") {
+		if vm, getErr := client.Esxclient.VMs.Get(id); getErr == nil && vm.State == "STARTED" {
+			err = fmt.Errorf("%s\nVM %s is running, --stop-if-needed stops it for the operation", err, id)
+		}
+	}
+
+	// The VM is started again even when the operation failed
+	if restart {
+		startErr := waitForTask(client.Esxclient.VMs.Start(id))
+		if startErr != nil {
+			if err != nil {
+				return fmt.Errorf("%s\nStarting VM %s again failed: %s", err, id, startErr)
+			}
+			return fmt.Errorf("Starting VM %s again failed: %s", id, startErr)
+		}
+		if !isScripting {
+			fmt.Fprintf(w, "Started VM %s\n", id)
+		}
+	}
+	return err
+}
+
+// Creates a persistent disk in the project and returns its ID
+func createVMDisk(projectID string, name string, flavor string, capacityGB int) (string, error) {
+	task, err := client.Esxclient.Projects.CreateDisk(projectID, &photon.DiskCreateSpec{
+		Name:       name,
+		Flavor:     flavor,
+		CapacityGB: capacityGB,
+		Kind:       "persistent-disk",
+	})
+	if err != nil {
+		return "", err
+	}
+	task, err = client.Esxclient.Tasks.Wait(task.ID)
+	if err != nil {
+		return "", fmt.Errorf("Creating disk %s failed: %s", name, err)
+	}
+	return task.Entity.ID, nil
+}
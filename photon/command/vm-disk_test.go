@@ -0,0 +1,188 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestChangeVMDisk(t *testing.T) {
+	vmID := "6b2d4e3f-5a7c-4d8e-0f9a-1b2c3d4e5f60"
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "fake_project_name", ID: "fake_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	vmResponse, err := json.Marshal(photon.VM{ID: vmID, Name: "db", State: "STARTED"})
+	if err != nil {
+		t.Error("Not expecting error serializing VM")
+	}
+	vmsResponse, err := json.Marshal(photon.VMs{Items: []photon.VM{{ID: vmID, Name: "db", State: "STARTED"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+	disksResponse, err := json.Marshal(photon.DiskList{Items: []photon.PersistentDisk{{ID: "disk-logs", Name: "logs"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing disks")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=fake_project_name",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+vmID,
+		mocks.CreateResponder(200, string(vmResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/fake_project_ID/disks",
+		mocks.CreateResponder(200, string(disksResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/fake_project_ID/vms",
+		mocks.CreateResponder(200, string(vmsResponse[:])))
+
+	// Records the calls in order, with the disk of the attach and detach operations
+	var calls []string
+	register := func(url string, call string, task photon.Task) {
+		taskResponse, err := json.Marshal(task)
+		if err != nil {
+			t.Error("Not expecting error serializing task")
+		}
+		mocks.RegisterResponder(
+			"POST",
+			url,
+			func(req *http.Request) (*http.Response, error) {
+				var operation photon.VmDiskOperation
+				body, _ := ioutil.ReadAll(req.Body)
+				json.Unmarshal(body, &operation)
+				if len(operation.DiskID) != 0 {
+					call += " " + operation.DiskID
+				}
+				calls = append(calls, call)
+				return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+			})
+		mocks.RegisterResponder(
+			"GET",
+			server.URL+"/tasks/"+task.ID,
+			mocks.CreateResponder(200, string(taskResponse[:])))
+	}
+	register(server.URL+"/projects/fake_project_ID/disks", "create",
+		photon.Task{ID: "disk-create-task", State: "COMPLETED", Entity: photon.Entity{ID: "disk-data"}})
+	register(server.URL+"/vms/"+vmID+"/stop", "stop",
+		photon.Task{ID: "disk-stop-task", State: "COMPLETED", Entity: photon.Entity{ID: vmID}})
+	register(server.URL+"/vms/"+vmID+"/start", "start",
+		photon.Task{ID: "disk-start-task", State: "COMPLETED", Entity: photon.Entity{ID: vmID}})
+	register(server.URL+"/vms/"+vmID+"/attach_disk", "attach",
+		photon.Task{ID: "disk-attach-task", State: "COMPLETED", Entity: photon.Entity{ID: vmID}})
+	register(server.URL+"/vms/"+vmID+"/detach_disk", "detach",
+		photon.Task{ID: "disk-detach-task", State: "ERROR", Entity: photon.Entity{ID: vmID}})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("disk", "data", "disk")
+	set.Bool("create", true, "create")
+	set.String("flavor", "disk-flavor", "flavor")
+	set.Int("capacityGB", 20, "capacityGB")
+	set.Bool("stop-if-needed", true, "stop-if-needed")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	err = set.Parse([]string{vmID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var buf bytes.Buffer
+	err = changeVMDisk(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "attach", &buf)
+	if err != nil {
+		t.Error("Not expecting attaching a new disk to fail: ", err)
+	}
+	if strings.Join(calls, ",") != "create,stop,attach disk-data,start" {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+
+	calls = nil
+	set = flag.NewFlagSet("test", 0)
+	set.String("disk", "logs", "disk")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	err = set.Parse([]string{"db"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = changeVMDisk(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "detach", &buf)
+	if err == nil || !strings.Contains(err.Error(), "--stop-if-needed") {
+		t.Errorf("Expected the failure on a running VM to suggest --stop-if-needed, got %v", err)
+	}
+	if strings.Join(calls, ",") != "detach disk-logs" {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+
+	calls = nil
+	set = flag.NewFlagSet("test", 0)
+	set.String("disk", "missing", "disk")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	err = set.Parse([]string{vmID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = changeVMDisk(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "detach", &buf)
+	if err == nil || !strings.Contains(err.Error(), "No disk matches 'missing'") {
+		t.Errorf("Expected a disk missing from the project to fail, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("Expected no calls for a disk that does not resolve, got %v", calls)
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("disk", "data", "disk")
+	set.Bool("create", true, "create")
+	err = set.Parse([]string{vmID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = changeVMDisk(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), "attach", &buf)
+	if err == nil {
+		t.Error("Expected --create without flavor and capacity to fail")
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/gopkg.in/yaml.v2"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// One metadata entry of a VM for the formatted outputs
+type vmMetadataEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Prints the metadata of a VM, or the value of one key
+func getVMMetadata(c *cli.Context, w io.Writer) error {
+	if len(c.Args()) == 0 || len(c.Args()) > 2 {
+		return checkArgNum(c.Args(), 1, "vm metadata get <id> [<key>]")
+	}
+	key := c.Args().Get(1)
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	if len(key) != 0 {
+		value, ok := vm.Metadata[key]
+		if !ok {
+			return fmt.Errorf("VM %s has no metadata key '%s'", vm.ID, key)
+		}
+		if utils.NeedsFormatting(c) {
+			utils.FormatObject(vmMetadataEntry{Key: key, Value: value}, w, c)
+		} else {
+			fmt.Fprintln(w, value)
+		}
+		return nil
+	}
+	return printVMMetadata(vm.Metadata, w, c)
+}
+
+// Sets metadata keys of a VM from key=value arguments and --from-file. The keys are merged
+// with the existing metadata, unless --replace is given.
+func setVMMetadataValues(c *cli.Context, w io.Writer) error {
+	if len(c.Args()) == 0 {
+		return checkArgNum(c.Args(), 1, "vm metadata set <id> [<key>=<value>...] [<options>]")
+	}
+	values := make(map[string]string)
+	if len(c.String("from-file")) != 0 {
+		var err error
+		values, err = readMetadataFile(c.String("from-file"))
+		if err != nil {
+			return err
+		}
+	}
+	for _, arg := range c.Args().Tail() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return fmt.Errorf("Invalid metadata '%s', should be <key>=<value>", arg)
+		}
+		values[parts[0]] = parts[1]
+	}
+	if len(values) == 0 && !c.Bool("replace") {
+		return errors.New("Please provide metadata as <key>=<value> or with --from-file")
+	}
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	if !c.Bool("replace") {
+		for key, value := range vm.Metadata {
+			metadata[key] = value
+		}
+	}
+	for key, value := range values {
+		metadata[key] = value
+	}
+	return applyVMMetadata(vm.ID, metadata, w, c)
+}
+
+// Removes metadata keys of a VM
+func unsetVMMetadata(c *cli.Context, w io.Writer) error {
+	if len(c.Args()) < 2 {
+		return checkArgNum(c.Args(), 2, "vm metadata unset <id> <key> [<key>...]")
+	}
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for key, value := range vm.Metadata {
+		metadata[key] = value
+	}
+	for _, key := range c.Args().Tail() {
+		if _, ok := metadata[key]; !ok {
+			return fmt.Errorf("VM %s has no metadata key '%s'", vm.ID, key)
+		}
+		delete(metadata, key)
+	}
+	return applyVMMetadata(vm.ID, metadata, w, c)
+}
+
+// Prints the tags of a VM
+func listVMTags(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "vm tag list <id>")
+	if err != nil {
+		return err
+	}
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, tag := range vm.Tags {
+			fmt.Fprintln(w, tag)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObject(vm.Tags, w, c)
+	} else {
+		for _, tag := range vm.Tags {
+			fmt.Fprintln(w, tag)
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(vm.Tags))
+	}
+	return nil
+}
+
+// Adds tags to a VM, the tags it already has are skipped
+func addVMTags(c *cli.Context, w io.Writer) error {
+	if len(c.Args()) < 2 {
+		return checkArgNum(c.Args(), 2, "vm tag add <id> <tag> [<tag>...]")
+	}
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	var added []string
+	for _, tag := range c.Args().Tail() {
+		if contains(vm.Tags, tag) || contains(added, tag) {
+			continue
+		}
+		err = waitForTask(client.Esxclient.VMs.SetTag(vm.ID, &photon.VmTag{Tag: tag}))
+		if err != nil {
+			return fmt.Errorf("Adding tag '%s' to VM %s failed: %s", tag, vm.ID, err)
+		}
+		added = append(added, tag)
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintln(w, vm.ID)
+	} else if !utils.NeedsFormatting(c) {
+		fmt.Fprintf(w, "Added %d tags to VM %s\n", len(added), vm.ID)
+	}
+	return nil
+}
+
+// The API can add tags to a VM but has no way to remove one. The command still checks the
+// tags so that the failure says whether there was anything to remove.
+func removeVMTags(c *cli.Context) error {
+	if len(c.Args()) < 2 {
+		return checkArgNum(c.Args(), 2, "vm tag remove <id> <tag> [<tag>...]")
+	}
+
+	vm, err := getVMForEditing(c)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range c.Args().Tail() {
+		if !contains(vm.Tags, tag) {
+			return fmt.Errorf("VM %s has no tag '%s'", vm.ID, tag)
+		}
+	}
+	return fmt.Errorf("Removing tags is not supported by the API, VM %s keeps its tags", vm.ID)
+}
+
+// Resolves the VM given as first argument and reads its current metadata and tags
+func getVMForEditing(c *cli.Context) (*photon.VM, error) {
+	var err error
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return nil, err
+	}
+	id, err := resolveVMID(c.Args().First())
+	if err != nil {
+		return nil, err
+	}
+	return client.Esxclient.VMs.Get(id)
+}
+
+func applyVMMetadata(id string, metadata map[string]string, w io.Writer, c *cli.Context) error {
+	err := waitForTask(client.Esxclient.VMs.SetMetadata(id, &photon.VmMetadata{Metadata: metadata}))
+	if err != nil {
+		return fmt.Errorf("Setting the metadata of VM %s failed: %s", id, err)
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintln(w, id)
+	} else if !utils.NeedsFormatting(c) {
+		fmt.Fprintf(w, "Updated the metadata of VM %s\n", id)
+	}
+	return nil
+}
+
+// Reads metadata from a YAML or JSON map of keys to scalar values
+func readMetadataFile(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, so a single parser reads both formats
+	var metadata map[string]string
+	err = yaml.Unmarshal(content, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read %s, it should be a map of keys to values: %s", path, err)
+	}
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	return metadata, nil
+}
+
+func printVMMetadata(metadata map[string]string, w io.Writer, c *cli.Context) error {
+	var keys []string
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\n", key, metadata[key])
+		}
+	} else if utils.NeedsFormatting(c) {
+		entries := []vmMetadataEntry{}
+		for _, key := range keys {
+			entries = append(entries, vmMetadataEntry{Key: key, Value: metadata[key]})
+		}
+		utils.FormatObjects(entries, w, c)
+	} else {
+		tw := new(tabwriter.Writer)
+		tw.Init(w, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Key\tValue\n")
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", key, metadata[key])
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nTotal: %d\n", len(keys))
+	}
+	return nil
+}
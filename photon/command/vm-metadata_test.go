@@ -0,0 +1,210 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Serves a VM and records the metadata and tags set on it
+func registerEditedVM(t *testing.T, serverURL string, vm photon.VM) (*[]map[string]string, *[]string) {
+	vmResponse, err := json.Marshal(vm)
+	if err != nil {
+		t.Error("Not expecting error serializing VM")
+	}
+	task := photon.Task{ID: "edit-task-" + vm.ID, State: "COMPLETED", Entity: photon.Entity{ID: vm.ID}}
+	taskResponse, err := json.Marshal(task)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+
+	var metadata []map[string]string
+	var tags []string
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/vms/"+vm.ID,
+		mocks.CreateResponder(200, string(vmResponse[:])))
+	mocks.RegisterResponder(
+		"POST",
+		serverURL+"/vms/"+vm.ID+"/set_metadata",
+		func(req *http.Request) (*http.Response, error) {
+			var spec photon.VmMetadata
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &spec)
+			metadata = append(metadata, spec.Metadata)
+			return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"POST",
+		serverURL+"/vms/"+vm.ID+"/tags",
+		func(req *http.Request) (*http.Response, error) {
+			var tag photon.VmTag
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &tag)
+			tags = append(tags, tag.Tag)
+			return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		serverURL+"/tasks/"+task.ID,
+		mocks.CreateResponder(200, string(taskResponse[:])))
+	return &metadata, &tags
+}
+
+func TestEditVMMetadata(t *testing.T) {
+	vmID := "4f0b2c1d-3e5a-4b6c-8d7e-9f0a1b2c3d4e"
+	server := mocks.NewTestServer()
+	defer server.Close()
+	metadata, _ := registerEditedVM(t, server.URL, photon.VM{
+		ID:       vmID,
+		Name:     "web",
+		Metadata: map[string]string{"owner": "ops", "tier": "web"},
+	})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	newContext := func(args []string, setup func(set *flag.FlagSet)) *cli.Context {
+		set := flag.NewFlagSet("test", 0)
+		set.String("from-file", "", "from-file")
+		set.Bool("replace", false, "replace")
+		setup(set)
+		err := set.Parse(args)
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	}
+	noFlags := func(set *flag.FlagSet) {}
+
+	var buf bytes.Buffer
+	err = getVMMetadata(newContext([]string{vmID}, noFlags), &buf)
+	if err != nil || buf.String() != "owner\tops\ntier\tweb\n" {
+		t.Errorf("Unexpected metadata output %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	err = getVMMetadata(newContext([]string{vmID, "owner"}, noFlags), &buf)
+	if err != nil || buf.String() != "ops\n" {
+		t.Errorf("Unexpected metadata value %q, %v", buf.String(), err)
+	}
+	err = getVMMetadata(newContext([]string{vmID, "missing"}, noFlags), &buf)
+	if err == nil {
+		t.Error("Expected getting a missing key to fail")
+	}
+
+	file := writeVMSpecFile(t, "metadata.yml", "team: build\nreplicas: 3\n")
+	defer os.RemoveAll(filepath.Dir(file))
+	buf.Reset()
+	err = setVMMetadataValues(newContext([]string{vmID, "owner=dev", "note=a=b"}, func(set *flag.FlagSet) {
+		set.Set("from-file", file)
+	}), &buf)
+	if err != nil || buf.String() != vmID+"\n" {
+		t.Errorf("Unexpected output of setting metadata %q, %v", buf.String(), err)
+	}
+	err = setVMMetadataValues(newContext([]string{vmID, "owner=dev"}, func(set *flag.FlagSet) {
+		set.Set("replace", "true")
+	}), ioutil.Discard)
+	if err != nil {
+		t.Error("Not expecting replacing metadata to fail: ", err)
+	}
+	err = unsetVMMetadata(newContext([]string{vmID, "tier"}, noFlags), ioutil.Discard)
+	if err != nil {
+		t.Error("Not expecting unsetting metadata to fail: ", err)
+	}
+	err = unsetVMMetadata(newContext([]string{vmID, "missing"}, noFlags), ioutil.Discard)
+	if err == nil {
+		t.Error("Expected unsetting a missing key to fail")
+	}
+	err = setVMMetadataValues(newContext([]string{vmID, "owner"}, noFlags), ioutil.Discard)
+	if err == nil {
+		t.Error("Expected metadata without a value to fail")
+	}
+
+	expected := []map[string]string{
+		{"owner": "dev", "tier": "web", "team": "build", "replicas": "3", "note": "a=b"},
+		{"owner": "dev"},
+		{"owner": "ops"},
+	}
+	if !reflect.DeepEqual(*metadata, expected) {
+		t.Errorf("Unexpected metadata set: %v", *metadata)
+	}
+}
+
+func TestEditVMTags(t *testing.T) {
+	vmID := "5a1c3d2e-4f6b-4c7d-9e8f-0a1b2c3d4e5f"
+	server := mocks.NewTestServer()
+	defer server.Close()
+	_, tags := registerEditedVM(t, server.URL, photon.VM{ID: vmID, Name: "web", Tags: []string{"env:prod"}})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	newContext := func(args ...string) *cli.Context {
+		set := flag.NewFlagSet("test", 0)
+		err := set.Parse(args)
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	}
+
+	var buf bytes.Buffer
+	err = listVMTags(newContext(vmID), &buf)
+	if err != nil || buf.String() != "env:prod\n" {
+		t.Errorf("Unexpected tags output %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	err = addVMTags(newContext(vmID, "env:prod", "team:web", "tier:front", "team:web"), &buf)
+	if err != nil || buf.String() != vmID+"\n" {
+		t.Errorf("Unexpected output of adding tags %q, %v", buf.String(), err)
+	}
+	if strings.Join(*tags, ",") != "team:web,tier:front" {
+		t.Errorf("Expected only the new tags to be added, got %v", *tags)
+	}
+
+	err = removeVMTags(newContext(vmID, "team:db"))
+	if err == nil || !strings.Contains(err.Error(), "no tag 'team:db'") {
+		t.Errorf("Expected removing a missing tag to fail, got %v", err)
+	}
+	err = removeVMTags(newContext(vmID, "env:prod"))
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected removing a tag to be reported as unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/gopkg.in/yaml.v2"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// A VM described in a YAML or JSON file for 'vm create --file'
+type vmSpecFile struct {
+	Name        string            `yaml:"name"`
+	Flavor      string            `yaml:"flavor"`
+	Image       string            `yaml:"image"`
+	Disks       []vmSpecDisk      `yaml:"disks"`
+	Environment map[string]string `yaml:"environment"`
+	Affinities  []vmSpecAffinity  `yaml:"affinities"`
+	Networks    []string          `yaml:"networks"`
+	Tags        []string          `yaml:"tags"`
+	Metadata    map[string]string `yaml:"metadata"`
+}
+
+type vmSpecDisk struct {
+	Name       string `yaml:"name"`
+	Flavor     string `yaml:"flavor"`
+	Kind       string `yaml:"kind"`
+	CapacityGB int    `yaml:"capacityGB"`
+	Boot       bool   `yaml:"boot"`
+}
+
+type vmSpecAffinity struct {
+	Kind string `yaml:"kind"`
+	ID   string `yaml:"id"`
+}
+
+// Fields of the name template of 'vm create --count'
+type vmNameTemplateData struct {
+	Name  string
+	Index int
+}
+
+// Creates one or more VMs from a spec file. The --set overrides are applied to the file before
+// it is read, and with --count the names of the VMs come from --name-template.
+func createVMsFromFile(c *cli.Context, w io.Writer) error {
+	for _, flag := range []string{"name", "flavor", "image", "disks", "environment", "affinities", "networks"} {
+		if len(c.String(flag)) != 0 {
+			return fmt.Errorf("--%s cannot be used with --file, use --set %s=<value> instead", flag, flag)
+		}
+	}
+	count := c.Int("count")
+	if count < 1 {
+		return errors.New("--count must be at least 1")
+	}
+	isScripting := utils.IsNonInteractive(c)
+	cloudInit, err := getCloudInitOptions(c)
+	if err != nil {
+		return err
+	}
+	if cloudInit != nil && len(cloudInit.hostname) != 0 && count > 1 {
+		return errors.New("--hostname cannot be used with --count, the host names are the VM names")
+	}
+
+	spec, err := loadVMSpecFile(c.String("file"), c.StringSlice("set"))
+	if err != nil {
+		return err
+	}
+	if len(spec.Name) == 0 || len(spec.Flavor) == 0 || len(spec.Image) == 0 {
+		return errors.New("The spec file must provide name, flavor and image")
+	}
+	names, err := getVMNames(spec.Name, count, c.String("name-template"))
+	if err != nil {
+		return err
+	}
+
+	client.Esxclient, err = client.GetClient(isScripting)
+	if err != nil {
+		return err
+	}
+
+	tenant, err := verifyTenant(c.String("tenant"))
+	if err != nil {
+		return err
+	}
+	project, err := verifyProject(tenant.ID, c.String("project"))
+	if err != nil {
+		return err
+	}
+
+	vmSpec, err := getVMCreateSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	if !isScripting {
+		fmt.Fprintf(w, "\nCreating %d VMs (%s) from image %s:\n", len(names), vmSpec.Flavor, vmSpec.SourceImageID)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if !confirmed(isScripting) {
+		fmt.Fprintln(w, "OK. Canceled")
+		return nil
+	}
+
+	for _, name := range names {
+		vmSpec.Name = name
+		createTask, err := client.Esxclient.Projects.CreateVM(project.ID, vmSpec)
+		if err != nil {
+			return fmt.Errorf("Creating VM %s failed: %s", name, err)
+		}
+		id, err := waitOnTaskOperation(createTask.ID, c)
+		if err != nil {
+			return fmt.Errorf("Creating VM %s failed: %s", name, err)
+		}
+		if len(spec.Metadata) != 0 {
+			err = waitForTask(client.Esxclient.VMs.SetMetadata(id, &photon.VmMetadata{Metadata: spec.Metadata}))
+			if err != nil {
+				return fmt.Errorf("Setting the metadata of VM %s failed: %s", name, err)
+			}
+		}
+		err = startCreatedVM(id, name, cloudInit, w, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads a VM spec from a YAML or JSON file and applies the key=value overrides to it.
+// Keys are dotted paths like 'flavor', 'metadata.owner' or 'disks.0.capacityGB'. Values are
+// strings, unless the field is a number, a boolean or a list, then they are read as YAML so
+// that '--set tags=[web,prod]' sets a list.
+func loadVMSpecFile(path string, overrides []string) (*vmSpecFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is YAML, so a single parser reads both formats
+	var doc interface{}
+	err = yaml.Unmarshal(content, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read %s: %s", path, err)
+	}
+	if doc == nil {
+		doc = map[interface{}]interface{}{}
+	}
+
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("Invalid --set '%s', should be <key>=<value>", override)
+		}
+		path := strings.Split(parts[0], ".")
+		var value interface{} = parts[1]
+		if fieldType := getSpecFieldType(reflect.TypeOf(vmSpecFile{}), path); fieldType != nil &&
+			fieldType.Kind() != reflect.String {
+			err = yaml.Unmarshal([]byte(parts[1]), &value)
+			if err != nil || value == nil {
+				value = parts[1]
+			}
+		}
+		doc, err = setSpecValue(doc, path, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --set '%s': %s", override, err)
+		}
+	}
+
+	content, err = yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	spec := &vmSpecFile{}
+	err = yaml.Unmarshal(content, spec)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read %s: %s", path, err)
+	}
+	return spec, nil
+}
+
+// Returns the type of the spec field at a --set path, or nil if there is no such field
+func getSpecFieldType(fieldType reflect.Type, path []string) reflect.Type {
+	for _, key := range path {
+		switch fieldType.Kind() {
+		case reflect.Struct:
+			var found reflect.Type
+			for i := 0; i < fieldType.NumField(); i++ {
+				field := fieldType.Field(i)
+				if strings.Split(field.Tag.Get("yaml"), ",")[0] == key {
+					found = field.Type
+					break
+				}
+			}
+			if found == nil {
+				return nil
+			}
+			fieldType = found
+		case reflect.Slice, reflect.Map:
+			fieldType = fieldType.Elem()
+		default:
+			return nil
+		}
+	}
+	return fieldType
+}
+
+// Sets the value at the path of a document read from YAML, creating the maps along the path
+func setSpecValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch node := doc.(type) {
+	case nil:
+		child, err := setSpecValue(nil, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[interface{}]interface{}{path[0]: child}, nil
+	case map[interface{}]interface{}:
+		child, err := setSpecValue(node[path[0]], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		index, err := strconv.Atoi(path[0])
+		if err != nil || index < 0 || index > len(node) {
+			return nil, fmt.Errorf("'%s' is not an index of a list of %d items", path[0], len(node))
+		}
+		if index == len(node) {
+			node = append(node, nil)
+		}
+		node[index], err = setSpecValue(node[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("'%s' is not a map or a list", path[0])
+	}
+}
+
+// Returns the names of the VMs to create, from the name template when there are several of them
+func getVMNames(name string, count int, nameTemplate string) ([]string, error) {
+	if count == 1 && len(nameTemplate) == 0 {
+		return []string{name}, nil
+	}
+	if len(nameTemplate) == 0 {
+		nameTemplate = "{{.Name}}-{{.Index}}"
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid name template: %s", err)
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for i := 1; i <= count; i++ {
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, vmNameTemplateData{Name: name, Index: i})
+		if err != nil {
+			return nil, fmt.Errorf("Invalid name template: %s", err)
+		}
+		if seen[buf.String()] {
+			return nil, fmt.Errorf("The name template gives several VMs the name '%s'", buf.String())
+		}
+		seen[buf.String()] = true
+		names = append(names, buf.String())
+	}
+	return names, nil
+}
+
+// Builds the create spec of a VM, resolving the names of the image and networks
+func getVMCreateSpec(spec *vmSpecFile) (*photon.VmCreateSpec, error) {
+	imageID, err := resolveImageID(spec.Image)
+	if err != nil {
+		return nil, err
+	}
+	vmSpec := &photon.VmCreateSpec{
+		Name:          spec.Name,
+		Flavor:        spec.Flavor,
+		SourceImageID: imageID,
+		Environment:   spec.Environment,
+		Tags:          spec.Tags,
+	}
+	for _, disk := range spec.Disks {
+		kind := disk.Kind
+		if len(kind) == 0 {
+			kind = "ephemeral-disk"
+		}
+		vmSpec.AttachedDisks = append(vmSpec.AttachedDisks, photon.AttachedDisk{
+			Name:       disk.Name,
+			Flavor:     disk.Flavor,
+			Kind:       kind,
+			CapacityGB: disk.CapacityGB,
+			BootDisk:   disk.Boot,
+		})
+	}
+	for _, affinity := range spec.Affinities {
+		vmSpec.Affinities = append(vmSpec.Affinities, photon.LocalitySpec{Kind: affinity.Kind, ID: affinity.ID})
+	}
+	for _, network := range spec.Networks {
+		networkID, err := resolveNetworkID(network)
+		if err != nil {
+			return nil, err
+		}
+		vmSpec.Networks = append(vmSpec.Networks, networkID)
+	}
+	return vmSpec, nil
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+const testVMSpecYAML = `
+name: web
+flavor: core-100
+image: 2f5e8a4c-6b1d-4c7e-9a3f-0d1e2c3b4a59
+disks:
+  - name: boot
+    flavor: core-100
+    boot: true
+  - name: data
+    flavor: core-100
+    capacityGB: 50
+environment:
+  url: "http://10.0.0.1:8080/a,b"
+affinities:
+  - kind: host
+    id: 10.0.0.1
+tags: [web]
+metadata:
+  owner: ops
+`
+
+const testVMSpecJSON = `{
+	"name": "web",
+	"flavor": "core-100",
+	"image": "2f5e8a4c-6b1d-4c7e-9a3f-0d1e2c3b4a59",
+	"disks": [
+		{"name": "boot", "flavor": "core-100", "boot": true},
+		{"name": "data", "flavor": "core-100", "capacityGB": 50}
+	],
+	"environment": {"url": "http://10.0.0.1:8080/a,b"},
+	"affinities": [{"kind": "host", "id": "10.0.0.1"}],
+	"tags": ["web"],
+	"metadata": {"owner": "ops"}
+}`
+
+func writeVMSpecFile(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "vm-spec")
+	if err != nil {
+		t.Fatal("Not expecting error creating a temporary directory: ", err)
+	}
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal("Not expecting error writing the spec file: ", err)
+	}
+	return path
+}
+
+func TestLoadVMSpecFile(t *testing.T) {
+	expected := &vmSpecFile{
+		Name:   "yes",
+		Flavor: "core-200",
+		Image:  "2f5e8a4c-6b1d-4c7e-9a3f-0d1e2c3b4a59",
+		Disks: []vmSpecDisk{
+			{Name: "boot", Flavor: "core-100", Boot: true},
+			{Name: "data", Flavor: "core-100", CapacityGB: 100},
+		},
+		Environment: map[string]string{"url": "http://10.0.0.1:8080/a,b"},
+		Affinities:  []vmSpecAffinity{{Kind: "host", ID: "10.0.0.1"}},
+		Tags:        []string{"web", "prod"},
+		Metadata:    map[string]string{"owner": "ops", "team": "a: b"},
+	}
+	overrides := []string{"name=yes", "flavor=core-200", "disks.1.capacityGB=100", "tags=[web, prod]",
+		"metadata.team=a: b"}
+
+	for _, file := range []struct{ Name, Content string }{
+		{"vm.yaml", testVMSpecYAML},
+		{"vm.json", testVMSpecJSON},
+	} {
+		path := writeVMSpecFile(t, file.Name, file.Content)
+		defer os.RemoveAll(filepath.Dir(path))
+
+		spec, err := loadVMSpecFile(path, overrides)
+		if err != nil {
+			t.Errorf("Not expecting loading %s to fail: %s", file.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, expected) {
+			t.Errorf("Unexpected spec from %s: %+v", file.Name, spec)
+		}
+	}
+
+	path := writeVMSpecFile(t, "vm.yaml", testVMSpecYAML)
+	defer os.RemoveAll(filepath.Dir(path))
+	for _, override := range []string{"flavor", "disks.5.name=x", "name.first=x"} {
+		_, err := loadVMSpecFile(path, []string{override})
+		if err == nil {
+			t.Errorf("Expected --set %s to fail", override)
+		}
+	}
+}
+
+func TestGetVMNames(t *testing.T) {
+	names, err := getVMNames("web", 3, "web-{{.Index}}")
+	if err != nil || !reflect.DeepEqual(names, []string{"web-1", "web-2", "web-3"}) {
+		t.Errorf("Unexpected names %v, %v", names, err)
+	}
+	names, err = getVMNames("web", 2, "")
+	if err != nil || !reflect.DeepEqual(names, []string{"web-1", "web-2"}) {
+		t.Errorf("Unexpected default names %v, %v", names, err)
+	}
+	_, err = getVMNames("web", 2, "web")
+	if err == nil {
+		t.Error("Expected a template giving every VM the same name to fail")
+	}
+}
+
+func TestCreateVMsFromFile(t *testing.T) {
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectResponse, err := json.Marshal(photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "fake_project_name", ID: "fake_project_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	task := photon.Task{ID: "fake-vm-task-ID", State: "COMPLETED", Entity: photon.Entity{ID: "fake_vm_ID"}}
+	taskResponse, err := json.Marshal(task)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects?name=fake_project_name",
+		mocks.CreateResponder(200, string(projectResponse[:])))
+	var specs []photon.VmCreateSpec
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/projects/fake_project_ID/vms",
+		func(req *http.Request) (*http.Response, error) {
+			var spec photon.VmCreateSpec
+			err := json.NewDecoder(req.Body).Decode(&spec)
+			if err != nil {
+				t.Error("Not expecting error decoding the create spec")
+			}
+			specs = append(specs, spec)
+			return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+		})
+	metadataCount := 0
+	mocks.RegisterResponder(
+		"POST",
+		server.URL+"/vms/fake_vm_ID/set_metadata",
+		func(req *http.Request) (*http.Response, error) {
+			metadataCount++
+			return mocks.CreateResponder(200, string(taskResponse[:]))(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+task.ID,
+		mocks.CreateResponder(200, string(taskResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	path := writeVMSpecFile(t, "vm.yaml", testVMSpecYAML)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("file", path, "file")
+	set.Var(&cli.StringSlice{"flavor=core-200"}, "set", "set")
+	set.Int("count", 2, "count")
+	set.String("name-template", "web-{{.Index}}", "name template")
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.String("project", "fake_project_name", "project")
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = createVMsFromFile(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting creating VMs from a file to fail: ", err)
+	}
+	if len(specs) != 2 || specs[0].Name != "web-1" || specs[1].Name != "web-2" {
+		t.Fatalf("Unexpected create specs: %+v", specs)
+	}
+	if specs[0].Flavor != "core-200" || len(specs[0].AttachedDisks) != 2 ||
+		specs[0].AttachedDisks[1].Kind != "ephemeral-disk" || specs[0].AttachedDisks[1].CapacityGB != 50 ||
+		specs[0].Environment["url"] != "http://10.0.0.1:8080/a,b" {
+		t.Errorf("Unexpected create spec: %+v", specs[0])
+	}
+	if metadataCount != 2 {
+		t.Errorf("Expected the metadata of 2 VMs to be set, got %d", metadataCount)
+	}
+}
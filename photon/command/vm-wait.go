@@ -0,0 +1,233 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+)
+
+// The conditions 'vm wait' waits for
+const (
+	vmWaitState = "state"
+	vmWaitIP    = "ip"
+	vmWaitPort  = "port"
+)
+
+// Checks of a VM while waiting for it start at the initial interval and double up to the
+// maximum interval, changed by tests
+var (
+	vmWaitInitialInterval = 1 * time.Second
+	vmWaitMaxInterval     = 30 * time.Second
+)
+
+// How long a connection to the port of a VM is tried
+var vmWaitDialTimeout = 3 * time.Second
+
+// How long 'vm create' waits for a new VM to report an IP address
+var vmIPTimeout = 10 * time.Minute
+
+// What 'vm wait' waits for: state=<state>, ip or port=<port>
+type vmWaitCondition struct {
+	kind  string
+	state string
+	port  int
+}
+
+// Why waiting for a VM cannot succeed, as opposed to the VM not being ready yet
+type vmWaitAbort struct {
+	reason string
+}
+
+func (abort vmWaitAbort) Error() string {
+	return abort.reason
+}
+
+// The outcome of 'vm wait' for the formatted outputs
+type vmWaitResult struct {
+	ID        string `json:"id"`
+	Condition string `json:"condition"`
+	IP        string `json:"ipAddress"`
+}
+
+// Waits until a VM reaches a state, reports an IP address or accepts connections on a port,
+// then prints its IP address
+func waitVM(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "vm wait <id> [<options>]")
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	condition, err := parseVMWaitCondition(c.String("for"))
+	if err != nil {
+		return err
+	}
+	timeout := c.Duration("timeout")
+	if timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
+	ip, err := waitForVM(id, condition, timeout)
+	if err != nil {
+		return err
+	}
+	if len(ip) == 0 {
+		ip = "-"
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintln(w, ip)
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObject(vmWaitResult{ID: id, Condition: condition.String(), IP: ip}, w, c)
+	} else {
+		fmt.Fprintf(w, "VM %s: %s, IP address: %s\n", id, condition, ip)
+	}
+	return nil
+}
+
+func parseVMWaitCondition(condition string) (*vmWaitCondition, error) {
+	parts := strings.SplitN(condition, "=", 2)
+	switch {
+	case parts[0] == vmWaitIP && len(parts) == 1:
+		return &vmWaitCondition{kind: vmWaitIP}, nil
+	case parts[0] == vmWaitState && len(parts) == 2 && len(parts[1]) != 0:
+		return &vmWaitCondition{kind: vmWaitState, state: strings.ToUpper(parts[1])}, nil
+	case parts[0] == vmWaitPort && len(parts) == 2:
+		port, err := strconv.Atoi(parts[1])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("Invalid port '%s'", parts[1])
+		}
+		return &vmWaitCondition{kind: vmWaitPort, port: port}, nil
+	}
+	return nil, fmt.Errorf("Invalid condition '%s', should be state=<state>, ip or port=<port>", condition)
+}
+
+func (condition *vmWaitCondition) String() string {
+	switch condition.kind {
+	case vmWaitState:
+		return vmWaitState + "=" + condition.state
+	case vmWaitPort:
+		return vmWaitPort + "=" + strconv.Itoa(condition.port)
+	}
+	return condition.kind
+}
+
+// Checks a VM with backoff until the condition is true. Returns the IP address of the VM,
+// which is empty when waiting for a state that the VM reached before reporting one.
+func waitForVM(id string, condition *vmWaitCondition, timeout time.Duration) (string, error) {
+	start := time.Now()
+	interval := vmWaitInitialInterval
+	for {
+		met, ip, reason := checkVMWaitCondition(id, condition)
+		if met {
+			return ip, nil
+		}
+		if _, ok := reason.(vmWaitAbort); ok {
+			return "", reason
+		}
+		if time.Since(start)+interval > timeout {
+			if reason != nil {
+				return "", fmt.Errorf("VM %s did not meet %s within %s: %s", id, condition, timeout, reason)
+			}
+			return "", fmt.Errorf("VM %s did not meet %s within %s", id, condition, timeout)
+		}
+		time.Sleep(interval)
+		interval *= 2
+		if interval > vmWaitMaxInterval {
+			interval = vmWaitMaxInterval
+		}
+	}
+}
+
+// Checks the condition once. When it is not met, the error tells why if there is more to it
+// than the VM not being ready yet. The VM no longer existing, or being in an error state, is
+// not worth waiting for and is reported as a vmWaitAbort.
+func checkVMWaitCondition(id string, condition *vmWaitCondition) (bool, string, error) {
+	if condition.kind == vmWaitState {
+		vm, err := client.Esxclient.VMs.Get(id)
+		if err != nil {
+			if apiErr, ok := err.(photon.ApiError); ok && apiErr.HttpStatusCode == 404 {
+				return false, "", vmWaitAbort{err.Error()}
+			}
+			return false, "", err
+		}
+		if vm.State != condition.state {
+			if vm.State == "ERROR" {
+				return false, "", vmWaitAbort{fmt.Sprintf("VM %s is in state ERROR", id)}
+			}
+			return false, "", nil
+		}
+		// Networks of stopped VMs have no IP address, don't run a task to find out
+		if vm.State != "STARTED" {
+			return true, "", nil
+		}
+		networks, err := getVMNetworks(id, true)
+		if err != nil {
+			return true, "", nil
+		}
+		return true, getVMIPAddress(networks), nil
+	}
+
+	// Getting the networks fails while the VM is powering on
+	networks, err := getVMNetworks(id, true)
+	if err != nil {
+		return false, "", err
+	}
+	ip := getVMIPAddress(networks)
+	if len(ip) == 0 || condition.kind == vmWaitIP {
+		return len(ip) != 0, ip, nil
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(condition.port)), vmWaitDialTimeout)
+	if err != nil {
+		return false, ip, err
+	}
+	conn.Close()
+	return true, ip, nil
+}
+
+// Waits until one of the networks of a VM has an IP address
+func waitForVMIP(id string, timeout time.Duration) (string, error) {
+	return waitForVM(id, &vmWaitCondition{kind: vmWaitIP}, timeout)
+}
+
+// Returns the first IP address of the connected VM networks, or an empty string
+func getVMIPAddress(networks []interface{}) string {
+	for _, nt := range networks {
+		network := nt.(map[string]interface{})
+		if val, ok := network["network"]; !ok || val == nil {
+			continue
+		}
+		if val, ok := network["isConnected"].(string); ok && val == "false" {
+			continue
+		}
+		if val, ok := network["ipAddress"].(string); ok && len(val) != 0 {
+			return val
+		}
+	}
+	return ""
+}
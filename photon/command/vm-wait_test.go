@@ -0,0 +1,176 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
+	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestParseVMWaitCondition(t *testing.T) {
+	for condition, expected := range map[string]vmWaitCondition{
+		"ip":            {kind: vmWaitIP},
+		"state=started": {kind: vmWaitState, state: "STARTED"},
+		"port=22":       {kind: vmWaitPort, port: 22},
+	} {
+		parsed, err := parseVMWaitCondition(condition)
+		if err != nil || *parsed != expected {
+			t.Errorf("Unexpected condition %+v, %v parsed from '%s'", parsed, err, condition)
+		}
+	}
+	for _, condition := range []string{"", "ip=1", "state", "state=", "port=ssh", "port=0", "cpu"} {
+		_, err := parseVMWaitCondition(condition)
+		if err == nil {
+			t.Errorf("Expected condition '%s' to be invalid", condition)
+		}
+	}
+}
+
+func TestWaitVM(t *testing.T) {
+	id := "7a1c3b2e-5d4f-4e6a-8b9c-0d1e2f3a4b5c"
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Not expecting error listening on a local port: ", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// The VM starts on the second check and reports an IP address on the second check of its networks
+	vmChecks := 0
+	networkChecks := 0
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+id,
+		func(req *http.Request) (*http.Response, error) {
+			vmChecks++
+			vm := photon.VM{ID: id, Name: "web1", State: "STOPPED"}
+			if vmChecks > 1 {
+				vm.State = "STARTED"
+			}
+			response, err := json.Marshal(vm)
+			if err != nil {
+				t.Error("Not expecting error serializing VM")
+			}
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+	networksTask := photon.Task{ID: "fake-wait-networks-task-ID", State: "COMPLETED"}
+	networksTaskResponse, err := json.Marshal(networksTask)
+	if err != nil {
+		t.Error("Not expecting error serializing task")
+	}
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+id+"/networks",
+		mocks.CreateResponder(200, string(networksTaskResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tasks/"+networksTask.ID,
+		func(req *http.Request) (*http.Response, error) {
+			networkChecks++
+			network := map[string]interface{}{"network": "VM VLAN"}
+			if networkChecks > 1 {
+				network["ipAddress"] = "127.0.0.1"
+			}
+			networksTask.ResourceProperties = map[string]interface{}{"networkConnections": []interface{}{network}}
+			response, err := json.Marshal(networksTask)
+			if err != nil {
+				t.Error("Not expecting error serializing task")
+			}
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+	vmWaitInitialInterval = time.Millisecond
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+
+	var tests = []struct {
+		Condition string
+		Output    string
+	}{
+		{"state=STARTED", "-\n"},
+		{"ip", "127.0.0.1\n"},
+		{"port=" + strconv.Itoa(port), "127.0.0.1\n"},
+	}
+	for _, test := range tests {
+		vmChecks, networkChecks = 0, 0
+		set := flag.NewFlagSet("test", 0)
+		set.String("for", test.Condition, "condition")
+		set.Duration("timeout", time.Minute, "timeout")
+		err = set.Parse([]string{id})
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+		var buf bytes.Buffer
+		err = waitVM(cxt, &buf)
+		if err != nil {
+			t.Errorf("Not expecting waiting for %s to fail: %s", test.Condition, err)
+		}
+		if buf.String() != test.Output {
+			t.Errorf("Waiting for %s printed '%s', expected '%s'", test.Condition, buf.String(), test.Output)
+		}
+	}
+
+	_, err = waitForVM(id, &vmWaitCondition{kind: vmWaitState, state: "SUSPENDED"}, 5*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not meet state=SUSPENDED") {
+		t.Errorf("Expected waiting for the VM to be suspended to time out, got %v", err)
+	}
+
+	// A failing API call is retried, a VM that does not exist is not waited for
+	statusCodes := []int{500, 200}
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/"+id,
+		func(req *http.Request) (*http.Response, error) {
+			statusCode := statusCodes[0]
+			if len(statusCodes) > 1 {
+				statusCodes = statusCodes[1:]
+			}
+			if statusCode != 200 {
+				response, _ := json.Marshal(photon.ApiError{Code: "Failure", Message: "failed"})
+				return mocks.CreateResponder(statusCode, string(response[:]))(req)
+			}
+			response, _ := json.Marshal(photon.VM{ID: id, Name: "web1", State: "STOPPED"})
+			return mocks.CreateResponder(200, string(response[:]))(req)
+		})
+	_, err = waitForVM(id, &vmWaitCondition{kind: vmWaitState, state: "STOPPED"}, time.Minute)
+	if err != nil {
+		t.Error("Expected a failing API call to be retried, got ", err)
+	}
+	statusCodes = []int{404}
+	_, err = waitForVM(id, &vmWaitCondition{kind: vmWaitState, state: "STOPPED"}, time.Minute)
+	if _, ok := err.(vmWaitAbort); !ok {
+		t.Errorf("Expected waiting for a missing VM to stop right away, got %v", err)
+	}
+}
@@ -12,12 +12,18 @@ package command
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+	"github.com/vmware/photon-controller-cli/photon/utils"
 
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/vmware/photon-controller-go-sdk/photon"
@@ -27,24 +33,34 @@ import (
 // Creates a cli.Command for vm
 // Subcommands:
 //      create;       Usage: vm create [<options>]
-//      delete;       Usage: vm delete <id>
-//      show;         Usage: vm show <id>
+//      delete;       Usage: vm delete <id> [<id>...] [<options>]
+//      show;         Usage: vm show <id> [<options>]
 //      list;         Usage: vm list [<options>]
 //      tasks;        Usage: vm tasks <id> [<options>]
-//      start;        Usage: vm start <id>
-//      stop;         Usage: vm stop <id>
-//      suspend;      Usage: vm suspend <id>
-//      resume;       Usage: vm resume <id>
-//      restart;      Usage: vm restart <id>
+//      start;        Usage: vm start <id> [<id>...] [<options>]
+//      stop;         Usage: vm stop <id> [<id>...] [<options>]
+//      suspend;      Usage: vm suspend <id> [<id>...] [<options>]
+//      resume;       Usage: vm resume <id> [<id>...] [<options>]
+//      restart;      Usage: vm restart <id> [<id>...] [<options>]
 //      attach-disk;  Usage: vm attach-disk <id> [<options>]
 //      detach-disk;  Usage: vm detach-disk <id> [<options>]
 //      attach-iso;   Usage: vm attach-iso <id> [<options>]
 //      detach-iso;   Usage: vm detach-iso <id> [<options>]
 //      set-metadata; Usage: vm set-metadata <id> [<options>]
 //      set-tag;      Usage: vm set-tag <id> [<options>]
+//      metadata get;   Usage: vm metadata get <id> [<key>]
+//      metadata set;   Usage: vm metadata set <id> [<key>=<value>...] [<options>]
+//      metadata unset; Usage: vm metadata unset <id> <key> [<key>...]
+//      tag list;     Usage: vm tag list <id>
+//      tag add;      Usage: vm tag add <id> <tag> [<tag>...]
+//      tag remove;   Usage: vm tag remove <id> <tag> [<tag>...]
 //      networks;     Usage: vm networks <id>
 //      mks-ticket;   Usage: vm mks-ticket <id>
+//      wait;         Usage: vm wait <id> [<options>]
+//      ssh;          Usage: vm ssh [<options>] <id> [-- <command>]
+//      console;      Usage: vm console <id> [<options>]
 //      create-image; Usage: vm create-image <id> [<options>]
+//      clone;        Usage: vm clone <id> [<options>]
 func GetVMCommand() cli.Command {
 	command := cli.Command{
 		Name:  "vm",
@@ -53,7 +69,7 @@ func GetVMCommand() cli.Command {
 			{
 				Name:  "create",
 				Usage: "Create a new VM",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
 						Usage: "VM name",
@@ -64,7 +80,7 @@ func GetVMCommand() cli.Command {
 					},
 					cli.StringFlag{
 						Name:  "image, i",
-						Usage: "Image ID",
+						Usage: "Image name or ID",
 					},
 					cli.StringFlag{
 						Name:  "disks, d",
@@ -80,7 +96,7 @@ func GetVMCommand() cli.Command {
 					},
 					cli.StringFlag{
 						Name:  "networks, w",
-						Usage: "VM Networks(id1, id2)",
+						Usage: "VM Networks, names or IDs (net1, net2)",
 					},
 					cli.StringFlag{
 						Name:  "tenant, t",
@@ -90,7 +106,29 @@ func GetVMCommand() cli.Command {
 						Name:  "project, p",
 						Usage: "Project name",
 					},
-				},
+					cli.StringFlag{
+						Name:  "file",
+						Usage: "YAML or JSON file describing the VM, instead of the flags above",
+					},
+					cli.StringSliceFlag{
+						Name:  "set",
+						Value: &cli.StringSlice{},
+						Usage: "Override a value of the file, as key=value (e.g. flavor=core-200, metadata.owner=ops)",
+					},
+					cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "Number of VMs to create from the file",
+					},
+					cli.StringFlag{
+						Name:  "name-template",
+						Usage: "Name of the VMs created with --count, e.g. web-{{.Index}}; {{.Name}} is the name in the file",
+					},
+					cli.BoolFlag{
+						Name:  "wait-for-ip",
+						Usage: "Start the VM and wait until it reports an IP address, then print it",
+					},
+				}, cloudInitFlags()...),
 				Action: func(c *cli.Context) {
 					err := createVM(c)
 					if err != nil {
@@ -100,7 +138,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "delete",
-				Usage: "Delete VM with specified ID",
+				Usage: "Delete VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := deleteVM(c)
 					if err != nil {
@@ -111,8 +150,19 @@ func GetVMCommand() cli.Command {
 			{
 				Name:  "show",
 				Usage: "Show VM info with specified ID",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "related",
+						Usage: "Also show the image name, the flavor cost, the persistent disks and the last tasks",
+					},
+					cli.IntFlag{
+						Name:  "tasks",
+						Value: 5,
+						Usage: "Number of tasks shown with --related",
+					},
+				},
 				Action: func(c *cli.Context) {
-					err := showVM(c)
+					err := showVM(c, os.Stdout)
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -121,7 +171,7 @@ func GetVMCommand() cli.Command {
 			{
 				Name:  "list",
 				Usage: "List all VMs",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "tenant, t",
 						Usage: "Tenant name",
@@ -138,9 +188,21 @@ func GetVMCommand() cli.Command {
 						Name:  "name, n",
 						Usage: "VM name",
 					},
-				},
+					cli.StringFlag{
+						Name:  "sort",
+						Usage: "Sort the VMs by name, state or host",
+					},
+					cli.BoolFlag{
+						Name:  "wide",
+						Usage: "Show the flavor, host, datastore, image and boot disk of the VMs",
+					},
+					cli.BoolFlag{
+						Name:  "all-projects",
+						Usage: "List the VMs of every project of the tenant",
+					},
+				}, append(vmFilterFlags(), pagingFlags()...)...),
 				Action: func(c *cli.Context) {
-					err := listVMs(c)
+					err := listVMs(c, os.Stdout)
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -164,7 +226,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "start",
-				Usage: "start VM",
+				Usage: "start VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := startVM(c)
 					if err != nil {
@@ -174,7 +237,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "stop",
-				Usage: "stop VM",
+				Usage: "stop VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := stopVM(c)
 					if err != nil {
@@ -184,7 +248,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "suspend",
-				Usage: "suspend VM",
+				Usage: "suspend VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := suspendVM(c)
 					if err != nil {
@@ -194,7 +259,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "resume",
-				Usage: "resume VM",
+				Usage: "resume VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := resumeVM(c)
 					if err != nil {
@@ -204,7 +270,8 @@ func GetVMCommand() cli.Command {
 			},
 			{
 				Name:  "restart",
-				Usage: "restart VM",
+				Usage: "restart VMs by ID, or the VMs matching the selectors",
+				Flags: vmSelectorFlags(),
 				Action: func(c *cli.Context) {
 					err := restartVM(c)
 					if err != nil {
@@ -215,12 +282,24 @@ func GetVMCommand() cli.Command {
 			{
 				Name:  "attach-disk",
 				Usage: "attach disk to VM",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "disk, d",
-						Usage: "Disk ID",
+						Usage: "Disk name or ID",
 					},
-				},
+					cli.BoolFlag{
+						Name:  "create",
+						Usage: "Create the disk named by --disk before attaching it",
+					},
+					cli.StringFlag{
+						Name:  "flavor, f",
+						Usage: "Flavor of the disk created with --create",
+					},
+					cli.IntFlag{
+						Name:  "capacityGB, c",
+						Usage: "Capacity in GB of the disk created with --create",
+					},
+				}, vmDiskFlags()...),
 				Action: func(c *cli.Context) {
 					err := attachDisk(c)
 					if err != nil {
@@ -231,12 +310,12 @@ func GetVMCommand() cli.Command {
 			{
 				Name:  "detach-disk",
 				Usage: "detach disk from VM",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					cli.StringFlag{
 						Name:  "disk, d",
-						Usage: "Disk ID",
+						Usage: "Disk name or ID",
 					},
-				},
+				}, vmDiskFlags()...),
 				Action: func(c *cli.Context) {
 					err := detachDisk(c)
 					if err != nil {
@@ -306,6 +385,88 @@ func GetVMCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "metadata",
+				Usage: "get and edit VM metadata",
+				Subcommands: []cli.Command{
+					{
+						Name:  "get",
+						Usage: "Print the metadata of a VM, or the value of one key",
+						Action: func(c *cli.Context) {
+							err := getVMMetadata(c, os.Stdout)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+					{
+						Name:  "set",
+						Usage: "Set metadata keys of a VM, merged with the existing metadata",
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:  "from-file, f",
+								Usage: "YAML or JSON file with a map of keys to values",
+							},
+							cli.BoolFlag{
+								Name:  "replace",
+								Usage: "Replace the existing metadata instead of merging with it",
+							},
+						},
+						Action: func(c *cli.Context) {
+							err := setVMMetadataValues(c, os.Stdout)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+					{
+						Name:  "unset",
+						Usage: "Remove metadata keys of a VM",
+						Action: func(c *cli.Context) {
+							err := unsetVMMetadata(c, os.Stdout)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+				},
+			},
+			{
+				Name:  "tag",
+				Usage: "list and add VM tags, the API cannot remove them",
+				Subcommands: []cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the tags of a VM",
+						Action: func(c *cli.Context) {
+							err := listVMTags(c, os.Stdout)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+					{
+						Name:  "add",
+						Usage: "Add tags to a VM",
+						Action: func(c *cli.Context) {
+							err := addVMTags(c, os.Stdout)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+					{
+						Name:  "remove",
+						Usage: "Remove tags from a VM, not supported by the API yet",
+						Action: func(c *cli.Context) {
+							err := removeVMTags(c)
+							if err != nil {
+								log.Fatal("Error: ", err)
+							}
+						},
+					},
+				},
+			},
 			{
 				Name:  "networks",
 				Usage: "show VM networks",
@@ -326,6 +487,75 @@ func GetVMCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "ssh",
+				Usage: "Run ssh against the first connected IP address of the VM",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "user, l",
+						Usage: "user to log in as",
+					},
+					cli.StringFlag{
+						Name:  "identity, i",
+						Usage: "private key file",
+					},
+					cli.IntFlag{
+						Name:  "port",
+						Usage: "ssh port of the VM",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := sshVM(c)
+					if status, ok := getExitStatus(err); ok {
+						os.Exit(status)
+					}
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "console",
+				Usage: "Print the console URL of the VM from a new MKS ticket",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "url-template",
+						Usage: "console URL, e.g. https://console/?host={{.Host}}&port={{.Port}}&ticket={{.Ticket}}; the default opens the VMware Remote Console",
+					},
+					cli.BoolFlag{
+						Name:  "open",
+						Usage: "open the URL with the default browser",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := getVMConsole(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:  "wait",
+				Usage: "Wait until a VM reaches a state, reports an IP address or accepts connections on a port",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "for",
+						Value: vmWaitIP,
+						Usage: "state=<state>, ip or port=<port>",
+					},
+					cli.DurationFlag{
+						Name:  "timeout",
+						Value: 10 * time.Minute,
+						Usage: "how long to wait",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := waitVM(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "create-image",
 				Usage: "Create an image by cloning VM",
@@ -346,6 +576,56 @@ func GetVMCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:  "clone",
+				Usage: "Create copies of a VM through an image of it",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "Name of the new VM, or base name with --count",
+					},
+					cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "Number of VMs to create",
+					},
+					cli.StringFlag{
+						Name:  "name-template",
+						Usage: "Names of the new VMs, e.g. {{.Name}}-{{.Index}}",
+					},
+					cli.StringFlag{
+						Name:  "networks",
+						Usage: "VM Networks, comma separated names or IDs; default: the networks of the source VM",
+					},
+					cli.StringFlag{
+						Name:  "image-name",
+						Usage: "Name of the intermediate image; default: clone-of-<VM name>",
+					},
+					cli.StringFlag{
+						Name:  "image-replication",
+						Value: "EAGER",
+						Usage: "Replication type of the intermediate image",
+					},
+					cli.BoolFlag{
+						Name:  "keep-image",
+						Usage: "Keep the intermediate image after the VMs are created",
+					},
+					cli.StringFlag{
+						Name:  "tenant, t",
+						Usage: "Tenant name",
+					},
+					cli.StringFlag{
+						Name:  "project, p",
+						Usage: "Project name",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := cloneVM(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 		},
 	}
 	return command
@@ -358,6 +638,16 @@ func createVM(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if len(c.String("file")) != 0 {
+		return createVMsFromFile(c, os.Stdout)
+	}
+	if c.Int("count") > 1 || len(c.String("name-template")) != 0 || len(c.StringSlice("set")) != 0 {
+		return fmt.Errorf("--count, --name-template and --set can only be used with --file")
+	}
+	cloudInit, err := getCloudInitOptions(c)
+	if err != nil {
+		return err
+	}
 
 	name := c.String("name")
 	flavor := c.String("flavor")
@@ -412,6 +702,11 @@ func createVM(c *cli.Context) error {
 		return fmt.Errorf("Please provide name, flavor and image")
 	}
 
+	imageID, err = resolveImageID(imageID)
+	if err != nil {
+		return err
+	}
+
 	var environmentMap map[string]string
 	if len(environment) != 0 {
 		environmentMap, err = parseMapFromFlag(environment)
@@ -428,6 +723,12 @@ func createVM(c *cli.Context) error {
 	var networkList []string
 	if len(networks) > 0 {
 		networkList = regexp.MustCompile(`\s*,\s*`).Split(networks, -1)
+		for i, network := range networkList {
+			networkList[i], err = resolveNetworkID(network)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	vmSpec := photon.VmCreateSpec{}
@@ -457,7 +758,11 @@ func createVM(c *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		_, err = waitOnTaskOperation(createTask.ID, c)
+		id, err := waitOnTaskOperation(createTask.ID, c)
+		if err != nil {
+			return err
+		}
+		err = startCreatedVM(id, name, cloudInit, os.Stdout, c)
 		if err != nil {
 			return err
 		}
@@ -468,43 +773,52 @@ func createVM(c *cli.Context) error {
 	return nil
 }
 
-// Sends a delete VM task to client based on the cli.Context
-// Returns an error if one occurred
-func deleteVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm delete <id>")
-	if err != nil {
-		return err
+// Customizes the guest of a new VM with cloud-init, which powers it on, and with --wait-for-ip
+// powers it on if needed and prints its IP address once the guest reports one
+func startCreatedVM(id string, name string, cloudInit *cloudInitOptions, w io.Writer, c *cli.Context) error {
+	var err error
+	if cloudInit != nil {
+		err = customizeVM(id, name, cloudInit, w, c)
+	} else if c.Bool("wait-for-ip") {
+		err = waitForTask(client.Esxclient.VMs.Start(id))
 	}
-	id := c.Args().First()
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
+	if err != nil || !c.Bool("wait-for-ip") {
 		return err
 	}
 
-	deleteTask, err := client.Esxclient.VMs.Delete(id)
+	ip, err := waitForVMIP(id, vmIPTimeout)
 	if err != nil {
 		return err
 	}
-
-	_, err = waitOnTaskOperation(deleteTask.ID, c)
-	if err != nil {
-		return err
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintln(w, ip)
+	} else {
+		fmt.Fprintf(w, "VM %s has IP address %s\n", name, ip)
 	}
-
 	return nil
 }
 
+// Sends a delete VM task to client based on the cli.Context
+// Returns an error if one occurred
+func deleteVM(c *cli.Context) error {
+	return runVMOperation(c, "delete", os.Stdout)
+}
+
 // Sends a show VM task to client based on the cli.Context
 // Returns an error if one occurred
-func showVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm show <id>")
+func showVM(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 1, "vm show <id> [<options>]")
 	if err != nil {
 		return err
 	}
 	id := c.Args().First()
 
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
+	client.Esxclient, err = client.GetClient(utils.IsNonInteractive(c))
+	if err != nil {
+		return err
+	}
+
+	id, err = resolveVMID(id)
 	if err != nil {
 		return err
 	}
@@ -513,9 +827,16 @@ func showVM(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	var networks []interface{}
-	if vm.State != "ERROR" {
-		networks, err = getVMNetworks(id, c.GlobalIsSet("non-interactive"))
+	details := vmDetails{VM: *vm}
+	// Networks of stopped VMs have no IP address, don't run a task to find out
+	if vm.State == "STARTED" {
+		details.Networks, err = getVMNetworks(id, utils.IsNonInteractive(c))
+		if err != nil {
+			return err
+		}
+	}
+	if c.Bool("related") {
+		err = getVMRelatedDetails(&details, c.Int("tasks"))
 		if err != nil {
 			return err
 		}
@@ -536,41 +857,71 @@ func showVM(c *cli.Context) error {
 			iso = append(iso, fmt.Sprintf("%s\t%s\t%s\t%d", i.ID, i.Name, i.Kind, i.Size))
 		}
 		scriptIso := strings.Join(iso, ",")
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", vm.ID, vm.Name, vm.State, vm.Flavor, vm.SourceImageID, vm.Host, vm.Datastore, scriptMetadata, scriptTag)
-		fmt.Printf("%s\n", scriptDisks)
-		fmt.Printf("%s\n", scriptIso)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", vm.ID, vm.Name, vm.State, vm.Flavor, vm.SourceImageID, vm.Host, vm.Datastore, scriptMetadata, scriptTag)
+		fmt.Fprintf(w, "%s\n", scriptDisks)
+		fmt.Fprintf(w, "%s\n", scriptIso)
 
-		err = printVMNetworks(networks, c.GlobalIsSet("non-interactive"))
+		err = printVMNetworks(details.Networks, w, true)
 		if err != nil {
 			return err
 		}
+
+		if c.Bool("related") {
+			persistentDisks := []string{}
+			for _, d := range details.PersistentDisks {
+				persistentDisks = append(persistentDisks, fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s", d.ID, d.Name, d.State, d.Flavor, d.CapacityGB, d.Datastore))
+			}
+			tasks := []string{}
+			for _, task := range details.Tasks {
+				tasks = append(tasks, fmt.Sprintf("%s\t%s\t%s\t%d\t%d", task.ID, task.State, task.Operation, task.StartedTime, task.EndTime-task.StartedTime))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", details.ImageName, quotaLineItemListToString(vm.Cost), quotaLineItemListToString(details.FlavorCost))
+			fmt.Fprintf(w, "%s\n", strings.Join(persistentDisks, ","))
+			fmt.Fprintf(w, "%s\n", strings.Join(tasks, ","))
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObject(details, w, c)
 	} else {
-		fmt.Println("VM ID: ", vm.ID)
-		fmt.Println("  Name:        ", vm.Name)
-		fmt.Println("  State:       ", vm.State)
-		fmt.Println("  Flavor:      ", vm.Flavor)
-		fmt.Println("  Source Image:", vm.SourceImageID)
-		fmt.Println("  Host:        ", vm.Host)
-		fmt.Println("  Datastore:   ", vm.Datastore)
-		fmt.Println("  Metadata:    ", vm.Metadata)
-		fmt.Println("  Disks:       ")
+		fmt.Fprintln(w, "VM ID: ", vm.ID)
+		fmt.Fprintln(w, "  Name:        ", vm.Name)
+		fmt.Fprintln(w, "  State:       ", vm.State)
+		fmt.Fprintln(w, "  Flavor:      ", vm.Flavor)
+		fmt.Fprintln(w, "  Source Image:", vm.SourceImageID)
+		if c.Bool("related") {
+			fmt.Fprintln(w, "  Image Name:  ", valueOrDash(details.ImageName))
+		}
+		fmt.Fprintln(w, "  Host:        ", vm.Host)
+		fmt.Fprintln(w, "  Datastore:   ", vm.Datastore)
+		fmt.Fprintln(w, "  Metadata:    ", vm.Metadata)
+		fmt.Fprintln(w, "  Cost:        ", quotaLineItemListToString(vm.Cost))
+		if c.Bool("related") {
+			fmt.Fprintln(w, "  Flavor Cost: ", quotaLineItemListToString(details.FlavorCost))
+		}
+		fmt.Fprintln(w, "  Disks:       ")
 		for i, d := range vm.AttachedDisks {
-			fmt.Printf("    Disk %d:\n", i+1)
-			fmt.Println("      ID:       ", d.ID)
-			fmt.Println("      Name:     ", d.Name)
-			fmt.Println("      Kind:     ", d.Kind)
-			fmt.Println("      Flavor:   ", d.Flavor)
-			fmt.Println("      Capacity: ", d.CapacityGB)
-			fmt.Println("      Boot:     ", d.BootDisk)
+			fmt.Fprintf(w, "    Disk %d:\n", i+1)
+			fmt.Fprintln(w, "      ID:       ", d.ID)
+			fmt.Fprintln(w, "      Name:     ", d.Name)
+			fmt.Fprintln(w, "      Kind:     ", d.Kind)
+			fmt.Fprintln(w, "      Flavor:   ", d.Flavor)
+			fmt.Fprintln(w, "      Capacity: ", d.CapacityGB)
+			fmt.Fprintln(w, "      Boot:     ", d.BootDisk)
+			for _, disk := range details.PersistentDisks {
+				if disk.ID == d.ID {
+					fmt.Fprintln(w, "      State:    ", disk.State)
+					fmt.Fprintln(w, "      Datastore:", disk.Datastore)
+					fmt.Fprintln(w, "      Tags:     ", disk.Tags)
+				}
+			}
 		}
 		for i, iso := range vm.AttachedISOs {
-			fmt.Printf("    ISO %d:\n", i+1)
-			fmt.Println("      Name: ", iso.Name)
-			fmt.Println("      Size: ", iso.Size)
+			fmt.Fprintf(w, "    ISO %d:\n", i+1)
+			fmt.Fprintln(w, "      Name: ", iso.Name)
+			fmt.Fprintln(w, "      Size: ", iso.Size)
 		}
-		for i, nt := range networks {
+		for i, nt := range details.Networks {
 			network := nt.(map[string]interface{})
-			fmt.Printf("    Networks: %d\n", i+1)
+			fmt.Fprintf(w, "    Networks: %d\n", i+1)
 			networkName := ""
 			ipAddr := ""
 			if val, ok := network["network"]; ok && val != nil {
@@ -579,247 +930,244 @@ func showVM(c *cli.Context) error {
 			if val, ok := network["ipAddress"]; ok && val != nil {
 				ipAddr = val.(string)
 			}
-			fmt.Println("      Name:       ", networkName)
-			fmt.Println("      IP Address: ", ipAddr)
+			fmt.Fprintln(w, "      Name:       ", networkName)
+			fmt.Fprintln(w, "      IP Address: ", ipAddr)
 		}
 		for i, tag := range vm.Tags {
-			fmt.Printf("    Tag %d:\n", i+1)
-			fmt.Println("      Tag Info:     ", tag)
+			fmt.Fprintf(w, "    Tag %d:\n", i+1)
+			fmt.Fprintln(w, "      Tag Info:     ", tag)
+		}
+		if c.Bool("related") {
+			fmt.Fprintln(w, "  Recent Tasks:")
+			for _, task := range details.Tasks {
+				fmt.Fprintf(w, "    %s  %s  %s  %s\n", task.ID, timestampToString(task.StartedTime), task.Operation, task.State)
+			}
 		}
 	}
 
 	return nil
 }
 
-// Retrieves a list of VMs, returns an error if one occurred
-func listVMs(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 0, "vm list [<options>]")
-	if err != nil {
-		return err
-	}
-
-	tenantName := c.String("tenant")
-	projectName := c.String("project")
-	summaryView := c.IsSet("summary")
-
-	name := c.String("name")
-	options := &photon.VmGetOptions{
-		Name: name,
-	}
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
-	}
-
-	tenant, err := verifyTenant(tenantName)
-	if err != nil {
-		return err
-	}
-	project, err := verifyProject(tenant.ID, projectName)
-	if err != nil {
-		return err
-	}
+// A VM with its networks and, with --related, the entities it refers to
+type vmDetails struct {
+	photon.VM
+	Networks        []interface{}           `json:"networks,omitempty"`
+	ImageName       string                  `json:"imageName,omitempty"`
+	FlavorCost      []photon.QuotaLineItem  `json:"flavorCost,omitempty"`
+	PersistentDisks []photon.PersistentDisk `json:"persistentDisks,omitempty"`
+	Tasks           []photon.Task           `json:"tasks,omitempty"`
+}
 
-	vmList, err := client.Esxclient.Projects.GetVMs(project.ID, options)
-	if err != nil {
-		return err
+type tasksByQueuedTimeSorter []photon.Task
+
+func (t tasksByQueuedTimeSorter) Len() int           { return len(t) }
+func (t tasksByQueuedTimeSorter) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t tasksByQueuedTimeSorter) Less(i, j int) bool { return t[i].QueuedTime < t[j].QueuedTime }
+
+// Resolves the image name and the flavor cost of a VM, and reads its persistent disks and
+// its last tasks, oldest first. The image may have been deleted since the VM was created.
+func getVMRelatedDetails(details *vmDetails, taskCount int) error {
+	if len(details.SourceImageID) != 0 {
+		image, err := client.Esxclient.Images.Get(details.SourceImageID)
+		if apiErr, ok := err.(photon.ApiError); ok && apiErr.HttpStatusCode == 404 {
+			err = nil
+		} else if err == nil {
+			details.ImageName = image.Name
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	err = printVMList(vmList.Items, os.Stdout, c, summaryView)
+	flavors, err := client.Esxclient.Flavors.GetAll(&photon.FlavorGetOptions{Name: details.Flavor, Kind: "vm"})
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-// Retrieves tasks for VM
-func getVMTasks(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm tasks <id> [<options>]")
-	if err != nil {
-		return err
+	for _, flavor := range flavors.Items {
+		if flavor.Name == details.Flavor {
+			details.FlavorCost = flavor.Cost
+		}
 	}
 
-	id := c.Args().First()
-	state := c.String("state")
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
+	for _, disk := range details.AttachedDisks {
+		if disk.Kind != "persistent-disk" {
+			continue
+		}
+		persistentDisk, err := client.Esxclient.Disks.Get(disk.ID)
+		if err != nil {
+			return err
+		}
+		details.PersistentDisks = append(details.PersistentDisks, *persistentDisk)
 	}
 
-	options := &photon.TaskGetOptions{
-		State: state,
+	if taskCount <= 0 {
+		return nil
 	}
-
-	taskList, err := client.Esxclient.VMs.GetTasks(id, options)
+	tasks, err := client.Esxclient.VMs.GetTasks(details.ID, nil)
 	if err != nil {
 		return err
 	}
-
-	err = printTaskList(taskList.Items, c)
-	if err != nil {
-		return err
+	details.Tasks = tasks.Items
+	sort.Sort(tasksByQueuedTimeSorter(details.Tasks))
+	if len(details.Tasks) > taskCount {
+		details.Tasks = details.Tasks[len(details.Tasks)-taskCount:]
 	}
-
 	return nil
 }
 
-func startVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm start <id>")
+// Retrieves a list of VMs, returns an error if one occurred
+func listVMs(c *cli.Context, w io.Writer) error {
+	err := checkArgNum(c.Args(), 0, "vm list [<options>]")
 	if err != nil {
 		return err
 	}
 
-	id := c.Args().First()
+	tenantName := c.String("tenant")
+	projectName := c.String("project")
+	summaryView := c.IsSet("summary")
+	allProjects := c.Bool("all-projects")
 
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
+	name := c.String("name")
+	paging, err := getListPaging(c)
 	if err != nil {
 		return err
 	}
-
-	opTask, err := client.Esxclient.VMs.Start(id)
+	filter, err := getVMFilter(c)
 	if err != nil {
 		return err
 	}
-
-	_, err = waitOnTaskOperation(opTask.ID, c)
+	sortKey, err := getVMListSortKey(c.String("sort"))
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func stopVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm stop <id>")
-	if err != nil {
-		return err
+	if allProjects && (len(projectName) != 0 || paging.limit != 0 || len(paging.pageToken) != 0) {
+		return fmt.Errorf("--all-projects cannot be used with --project, --limit or --page-token")
 	}
 
-	id := c.Args().First()
-
 	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
 
-	opTask, err := client.Esxclient.VMs.Stop(id)
+	tenant, err := verifyTenant(tenantName)
 	if err != nil {
 		return err
 	}
-
-	_, err = waitOnTaskOperation(opTask.ID, c)
-	if err != nil {
-		return err
+	var projects []cf.ProjectConfiguration
+	if allProjects {
+		projectList, err := client.Esxclient.Tenants.GetProjects(tenant.ID, nil)
+		if err != nil {
+			return err
+		}
+		for _, project := range projectList.Items {
+			projects = append(projects, cf.ProjectConfiguration{Name: project.Name, ID: project.ID})
+		}
+	} else {
+		project, err := verifyProject(tenant.ID, projectName)
+		if err != nil {
+			return err
+		}
+		projects = append(projects, *project)
 	}
-
-	return nil
-}
-
-func suspendVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm suspend <id>")
+	err = filter.resolve()
 	if err != nil {
 		return err
 	}
 
-	id := c.Args().First()
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
+	query := url.Values{}
+	if len(name) != 0 {
+		query.Set("name", name)
 	}
 
-	opTask, err := client.Esxclient.VMs.Suspend(id)
-	if err != nil {
-		return err
+	// Filters apply to the pages fetched from the server, so that --limit and --page-token keep
+	// paging through the whole list. Sorted lists are printed once every page is fetched.
+	printer := newVMListPrinter(w, c, summaryView, c.Bool("wide"), allProjects)
+	var sorted []vmListItem
+	var nextPageToken string
+	for _, project := range projects {
+		nextPageToken, err = streamList("/projects/"+project.ID+"/vms", query, paging, func(items []json.RawMessage) error {
+			var selected []vmListItem
+			for _, item := range items {
+				var vm photon.VM
+				err := json.Unmarshal(item, &vm)
+				if err != nil {
+					return err
+				}
+				if filter.matches(vm) {
+					selected = append(selected, vmListItem{VM: vm, Project: project.Name})
+				}
+			}
+			if sortKey != nil {
+				sorted = append(sorted, selected...)
+				return nil
+			}
+			return printer.printPage(selected)
+		})
+		if err != nil {
+			return err
+		}
 	}
-
-	_, err = waitOnTaskOperation(opTask.ID, c)
+	if sortKey != nil {
+		sort.Stable(vmListItemsBy{sorted, sortKey})
+		err = printer.printPage(sorted)
+		if err != nil {
+			return err
+		}
+	}
+	err = printer.done()
 	if err != nil {
 		return err
 	}
+	printNextPageToken(nextPageToken, w, c)
 
 	return nil
 }
 
-func resumeVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm resume <id>")
-	if err != nil {
-		return err
-	}
-
-	id := c.Args().First()
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
-	}
-
-	opTask, err := client.Esxclient.VMs.Resume(id)
-	if err != nil {
-		return err
-	}
-
-	_, err = waitOnTaskOperation(opTask.ID, c)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// Returns the value the VMs are sorted by, nil when they are not sorted
+func getVMListSortKey(column string) (func(item vmListItem) string, error) {
+	switch column {
+	case "":
+		return nil, nil
+	case "name":
+		return func(item vmListItem) string { return item.Name }, nil
+	case "state":
+		return func(item vmListItem) string { return item.State }, nil
+	case "host":
+		return func(item vmListItem) string { return item.Host }, nil
+	}
+	return nil, fmt.Errorf("Invalid --sort '%s', should be name, state or host", column)
 }
 
-func restartVM(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm restart <id>")
+// Retrieves tasks for VM
+func getVMTasks(c *cli.Context) error {
+	err := checkArgNum(c.Args(), 1, "vm tasks <id> [<options>]")
 	if err != nil {
 		return err
 	}
 
 	id := c.Args().First()
+	state := c.String("state")
 
 	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
 
-	opTask, err := client.Esxclient.VMs.Restart(id)
-	if err != nil {
-		return err
-	}
-
-	_, err = waitOnTaskOperation(opTask.ID, c)
+	id, err = resolveVMID(id)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func attachDisk(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm attach-disk <id> [<options>]")
-	if err != nil {
-		return err
-	}
-
-	id := c.Args().First()
-	diskID := c.String("disk")
-
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
-	}
-
-	operation := &photon.VmDiskOperation{
-		DiskID: diskID,
+	options := &photon.TaskGetOptions{
+		State: state,
 	}
 
-	task, err := client.Esxclient.VMs.AttachDisk(id, operation)
+	taskList, err := client.Esxclient.VMs.GetTasks(id, options)
 	if err != nil {
 		return err
 	}
 
-	_, err = waitOnTaskOperation(task.ID, c)
+	err = printTaskList(taskList.Items, c)
 	if err != nil {
 		return err
 	}
@@ -827,35 +1175,32 @@ func attachDisk(c *cli.Context) error {
 	return nil
 }
 
-func detachDisk(c *cli.Context) error {
-	err := checkArgNum(c.Args(), 1, "vm detach-disk <id> [<options>]")
-	if err != nil {
-		return err
-	}
+func startVM(c *cli.Context) error {
+	return runVMOperation(c, "start", os.Stdout)
+}
 
-	id := c.Args().First()
-	diskID := c.String("disk")
+func stopVM(c *cli.Context) error {
+	return runVMOperation(c, "stop", os.Stdout)
+}
 
-	client.Esxclient, err = client.GetClient(c.GlobalIsSet("non-interactive"))
-	if err != nil {
-		return err
-	}
+func suspendVM(c *cli.Context) error {
+	return runVMOperation(c, "suspend", os.Stdout)
+}
 
-	operation := &photon.VmDiskOperation{
-		DiskID: diskID,
-	}
+func resumeVM(c *cli.Context) error {
+	return runVMOperation(c, "resume", os.Stdout)
+}
 
-	task, err := client.Esxclient.VMs.DetachDisk(id, operation)
-	if err != nil {
-		return err
-	}
+func restartVM(c *cli.Context) error {
+	return runVMOperation(c, "restart", os.Stdout)
+}
 
-	_, err = waitOnTaskOperation(task.ID, c)
-	if err != nil {
-		return err
-	}
+func attachDisk(c *cli.Context) error {
+	return changeVMDisk(c, "attach", os.Stdout)
+}
 
-	return nil
+func detachDisk(c *cli.Context) error {
+	return changeVMDisk(c, "detach", os.Stdout)
 }
 
 func attachIso(c *cli.Context) error {
@@ -901,6 +1246,11 @@ func attachIso(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.VMs.AttachISO(id, file, name)
 	if err != nil {
 		return err
@@ -927,6 +1277,11 @@ func detachIso(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.VMs.DetachISO(id)
 	if err != nil {
 		return err
@@ -953,6 +1308,11 @@ func setVMMetadata(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	metadata := c.String("metadata")
 	vmMetadata := &photon.VmMetadata{}
 
@@ -993,11 +1353,16 @@ func listVMNetworks(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	networks, err := getVMNetworks(id, c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
-	err = printVMNetworks(networks, c.GlobalIsSet("non-interactive"))
+	err = printVMNetworks(networks, os.Stdout, c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
@@ -1025,6 +1390,11 @@ func setVMTag(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.VMs.SetTag(id, vmTag)
 	if err != nil {
 		return err
@@ -1051,6 +1421,11 @@ func getVMMksTicket(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.VMs.GetMKSTicket(id)
 	if err != nil {
 		return err
@@ -1116,6 +1491,11 @@ func createVmImage(c *cli.Context) error {
 		return err
 	}
 
+	id, err = resolveVMID(id)
+	if err != nil {
+		return err
+	}
+
 	task, err := client.Esxclient.VMs.CreateImage(id, options)
 	if err != nil {
 		return err
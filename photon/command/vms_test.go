@@ -10,9 +10,11 @@
 package command
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
@@ -96,6 +98,7 @@ func TestCreateDeleteVM(t *testing.T) {
 		mocks.CreateResponder(200, string(response[:])))
 	defer server.Close()
 
+	registerResolveList(server.URL+"/images", "fake_image_ID")
 	mocks.Activate(true)
 	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
 	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
@@ -203,7 +206,7 @@ func TestShowVM(t *testing.T) {
 	}
 	cxt := cli.NewContext(nil, set, nil)
 
-	err = showVM(cxt)
+	err = showVM(cxt, os.Stdout)
 	if err != nil {
 		t.Error("Not expecting error showing VM: " + err.Error())
 	}
@@ -714,7 +717,7 @@ func TestListVMs(t *testing.T) {
 	set.String("project", "fake_project_name", "project name")
 	cxt := cli.NewContext(nil, set, nil)
 
-	err = listVMs(cxt)
+	err = listVMs(cxt, os.Stdout)
 	if err != nil {
 		t.Error("Not expecting error listing VMs: " + err.Error())
 	}
@@ -821,7 +824,7 @@ func TestFindVMsByName(t *testing.T) {
 	set.String("name", vmName, "VM name")
 	cxt := cli.NewContext(nil, set, nil)
 
-	err = listVMs(cxt)
+	err = listVMs(cxt, os.Stdout)
 	if err != nil {
 		t.Error("Not expecting error listing VMs by name: " + err.Error())
 	}
@@ -1159,3 +1162,202 @@ func TestCreateVMImage(t *testing.T) {
 		t.Error("Not expecting error creating VM image: " + err.Error())
 	}
 }
+
+func TestListVMsOfAllProjects(t *testing.T) {
+	tenantResponse, err := json.Marshal(photon.Tenants{Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}}})
+	if err != nil {
+		t.Error("Not expecting error serializing tenants")
+	}
+	projectsResponse, err := json.Marshal(photon.ProjectList{Items: []photon.ProjectCompact{
+		{Name: "dev", ID: "dev_project_ID"},
+		{Name: "ops", ID: "ops_project_ID"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing projects")
+	}
+	devResponse, err := json.Marshal(MockVMsPage{Items: []photon.VM{
+		{ID: "vm-3", Name: "web", State: "STARTED", Flavor: "core-100", Host: "10.0.0.1", SourceImageID: "image-1",
+			AttachedDisks: []photon.AttachedDisk{{Name: "boot", BootDisk: true}}},
+		{ID: "vm-4", Name: "db", State: "STOPPED", Flavor: "core-200"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+	opsResponse, err := json.Marshal(MockVMsPage{Items: []photon.VM{
+		{ID: "vm-5", Name: "monitor", State: "STARTED", Flavor: "core-100"},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing VMs")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants",
+		mocks.CreateResponder(200, string(tenantResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/tenants/fake_tenant_ID/projects",
+		mocks.CreateResponder(200, string(projectsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/dev_project_ID/vms",
+		mocks.CreateResponder(200, string(devResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/projects/ops_project_ID/vms",
+		mocks.CreateResponder(200, string(opsResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.Bool("non-interactive", true, "non-interactive")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("tenant", "fake_tenant_name", "tenant")
+	set.Bool("all-projects", true, "all projects")
+	set.Bool("wide", true, "wide")
+	set.String("state", "STARTED", "state")
+	set.String("sort", "name", "sort")
+	err = set.Parse([]string{"--all-projects", "--wide"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = listVMs(cxt, &buf)
+	if err != nil {
+		t.Error("Not expecting listing the VMs of all projects to fail: ", err)
+	}
+	expected := "ops\tvm-5\tmonitor\tSTARTED\tcore-100\t-\t-\t-\t-\n" +
+		"dev\tvm-3\tweb\tSTARTED\tcore-100\t10.0.0.1\t-\timage-1\tboot\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected list, expected:\n%sgot:\n%s", expected, buf.String())
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("sort", "flavor", "sort")
+	err = listVMs(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &buf)
+	if err == nil {
+		t.Error("Expected sorting by an unknown column to fail")
+	}
+}
+
+func TestShowVMRelated(t *testing.T) {
+	vmResponse, err := json.Marshal(photon.VM{
+		ID:            "show-vm-1",
+		Name:          "db",
+		State:         "STOPPED",
+		Flavor:        "core-100",
+		SourceImageID: "deleted-image",
+		Cost:          []photon.QuotaLineItem{{Key: "vm.cpu", Value: 1, Unit: "COUNT"}},
+		AttachedDisks: []photon.AttachedDisk{
+			{ID: "boot-disk", Name: "boot", Kind: "ephemeral-disk", BootDisk: true},
+			{ID: "data-disk", Name: "data", Kind: "persistent-disk", CapacityGB: 50},
+		},
+	})
+	if err != nil {
+		t.Error("Not expecting error serializing VM")
+	}
+	flavorsResponse, err := json.Marshal(photon.FlavorList{Items: []photon.Flavor{
+		{Name: "core-100", Kind: "vm", Cost: []photon.QuotaLineItem{{Key: "vm.memory", Value: 2, Unit: "GB"}}},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing flavors")
+	}
+	diskResponse, err := json.Marshal(photon.PersistentDisk{ID: "data-disk", Name: "data", State: "ATTACHED", Datastore: "ds-1"})
+	if err != nil {
+		t.Error("Not expecting error serializing disk")
+	}
+	tasksResponse, err := json.Marshal(photon.TaskList{Items: []photon.Task{
+		{ID: "task-3", Operation: "STOP_VM", State: "COMPLETED", QueuedTime: 3000},
+		{ID: "task-1", Operation: "CREATE_VM", State: "COMPLETED", QueuedTime: 1000},
+		{ID: "task-2", Operation: "START_VM", State: "COMPLETED", QueuedTime: 2000},
+	}})
+	if err != nil {
+		t.Error("Not expecting error serializing tasks")
+	}
+
+	server := mocks.NewTestServer()
+	defer server.Close()
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/show-vm-1",
+		mocks.CreateResponder(200, string(vmResponse[:])))
+	networksRequested := false
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/show-vm-1/networks",
+		func(req *http.Request) (*http.Response, error) {
+			networksRequested = true
+			return mocks.CreateResponder(500, `{"code":"InternalError"}`)(req)
+		})
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/images/deleted-image",
+		mocks.CreateResponder(404, `{"code":"ImageNotFound","message":"Image deleted-image not found"}`))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/flavors?name=core-100&kind=vm",
+		mocks.CreateResponder(200, string(flavorsResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/disks/data-disk",
+		mocks.CreateResponder(200, string(diskResponse[:])))
+	mocks.RegisterResponder(
+		"GET",
+		server.URL+"/vms/show-vm-1/tasks",
+		mocks.CreateResponder(200, string(tasksResponse[:])))
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Esxclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("global", 0)
+	globalSet.String("output", "json", "output")
+	err = globalSet.Parse([]string{"--output", "json"})
+	if err != nil {
+		t.Error("Not expecting global arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("related", true, "related")
+	set.Int("tasks", 2, "tasks")
+	err = set.Parse([]string{"show-vm-1"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+
+	var buf bytes.Buffer
+	err = showVM(cxt, &buf)
+	if err != nil {
+		t.Fatal("Not expecting showing the VM to fail: ", err)
+	}
+	if networksRequested {
+		t.Error("Expected the networks of a stopped VM not to be requested")
+	}
+	var details vmDetails
+	err = json.Unmarshal(buf.Bytes(), &details)
+	if err != nil {
+		t.Fatal("Not expecting error unmarshalling the VM: ", err)
+	}
+	if details.ID != "show-vm-1" || len(details.Cost) != 1 || details.ImageName != "" {
+		t.Errorf("Unexpected VM details: %+v", details)
+	}
+	if len(details.FlavorCost) != 1 || details.FlavorCost[0].Key != "vm.memory" {
+		t.Errorf("Unexpected flavor cost: %+v", details.FlavorCost)
+	}
+	if len(details.PersistentDisks) != 1 || details.PersistentDisks[0].Datastore != "ds-1" {
+		t.Errorf("Unexpected persistent disks: %+v", details.PersistentDisks)
+	}
+	if len(details.Tasks) != 2 || details.Tasks[0].ID != "task-2" || details.Tasks[1].ID != "task-3" {
+		t.Errorf("Expected the last two tasks, got %+v", details.Tasks)
+	}
+}
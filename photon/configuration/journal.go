@@ -0,0 +1,109 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// Progress of a "system deploy" run. It is kept next to the config file so that
+// an interrupted deployment can be resumed without creating duplicate entities.
+type DeploymentJournal struct {
+	DcMap             string
+	DeploymentID      string
+	AvailabilityZones map[string]string
+	Hosts             map[string]string
+}
+
+// Creates an empty journal for the given DC map
+func NewDeploymentJournal(dcMap string) *DeploymentJournal {
+	return &DeploymentJournal{
+		DcMap:             dcMap,
+		AvailabilityZones: make(map[string]string),
+		Hosts:             make(map[string]string),
+	}
+}
+
+// Load the deployment journal, returns nil if no deployment is in progress
+func LoadDeploymentJournal() (*DeploymentJournal, error) {
+	filepath, err := getDeploymentJournalFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isFileExist(filepath) {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading deployment journal: %v", err)
+	}
+
+	journal := NewDeploymentJournal("")
+	err = json.Unmarshal(data, journal)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading deployment journal: %v", err)
+	}
+	if journal.AvailabilityZones == nil {
+		journal.AvailabilityZones = make(map[string]string)
+	}
+	if journal.Hosts == nil {
+		journal.Hosts = make(map[string]string)
+	}
+
+	return journal, nil
+}
+
+// Save the deployment journal, will overwrite the previous one
+func SaveDeploymentJournal(journal *DeploymentJournal) error {
+	filepath, err := getDeploymentJournalFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return fmt.Errorf("Error saving deployment journal: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Error saving deployment journal: %v", err)
+	}
+
+	return nil
+}
+
+// Remove the deployment journal once the deployment is complete
+func RemoveDeploymentJournal() error {
+	filepath, err := getDeploymentJournalFilePath()
+	if err != nil {
+		return err
+	}
+
+	if isFileExist(filepath) {
+		return os.Remove(filepath)
+	}
+	return nil
+}
+
+// Get path of the deployment journal: $HOME_DIR/.photon-cli/.photon-deploy-journal
+func getDeploymentJournalFilePath() (string, error) {
+	userConfigDir, err := getUserConfigDirectory()
+	if err == nil {
+		return path.Join(userConfigDir, ".photon-deploy-journal"), nil
+	}
+	return userConfigDir, err
+}
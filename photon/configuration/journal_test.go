@@ -0,0 +1,72 @@
+// Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration_test
+
+import (
+	. "github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/onsi/ginkgo"
+	. "github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/onsi/gomega"
+	. "github.com/vmware/photon-controller-cli/photon/configuration"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("DeploymentJournal", func() {
+	BeforeEach(func() {
+		var err error
+		UserConfigDir, err = ioutil.TempDir("", "journal-test-")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		err := RemoveDeploymentJournal()
+		err2 := os.Remove(UserConfigDir)
+		Expect(err).To(BeNil())
+		Expect(err2).To(BeNil())
+	})
+
+	Context("when journal does not exist", func() {
+		It("returns nil and no error", func() {
+			journal, err := LoadDeploymentJournal()
+
+			Expect(err).To(BeNil())
+			Expect(journal).To(BeNil())
+		})
+	})
+
+	Context("when journal was saved", func() {
+		var journalExpected *DeploymentJournal
+
+		BeforeEach(func() {
+			journalExpected = NewDeploymentJournal("/tmp/dcmap.yml")
+			journalExpected.DeploymentID = "deployment-id"
+			journalExpected.AvailabilityZones["az1"] = "az1-id"
+			journalExpected.Hosts["10.0.0.1"] = "host-id"
+
+			err := SaveDeploymentJournal(journalExpected)
+			Expect(err).To(BeNil())
+		})
+
+		It("returns the journal", func() {
+			journal, err := LoadDeploymentJournal()
+
+			Expect(err).To(BeNil())
+			Expect(journal).To(BeEquivalentTo(journalExpected))
+		})
+
+		It("is gone after removal", func() {
+			err := RemoveDeploymentJournal()
+			Expect(err).To(BeNil())
+
+			journal, err := LoadDeploymentJournal()
+			Expect(err).To(BeNil())
+			Expect(journal).To(BeNil())
+		})
+	})
+})
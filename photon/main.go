@@ -46,7 +46,7 @@ func BuildApp() *cli.App {
 		},
 		cli.StringFlag{
 			Name:  "output, o",
-			Usage: "Select output format",
+			Usage: "Select output format: json or csv. csv is meant for list commands, nested objects are written as JSON",
 		},
 	}
 	app.Commands = []cli.Command{
@@ -10,14 +10,36 @@
 package manifest
 
 import (
+	"fmt"
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/gopkg.in/yaml.v2"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 )
 
 type Installation struct {
-	Deployment deployment `yaml:"deployment"`
-	Hosts      []host     `yaml:"hosts"`
+	Include    []string     `yaml:"include,omitempty"`
+	Defaults   hostDefaults `yaml:"defaults,omitempty"`
+	Deployment deployment   `yaml:"deployment"`
+	Hosts      []host       `yaml:"hosts"`
+}
+
+// Host group file pulled in through the "include" list of a DC map. It may define
+// its own defaults, which are layered on top of the defaults of the including file.
+type hostGroup struct {
+	Include  []string     `yaml:"include"`
+	Defaults hostDefaults `yaml:"defaults"`
+	Hosts    []host       `yaml:"hosts"`
+}
+
+// Values inherited by every host entry that does not set them itself.
+type hostDefaults struct {
+	Username         string            `yaml:"username,omitempty"`
+	Password         string            `yaml:"password,omitempty"`
+	AvailabilityZone string            `yaml:"availability_zone,omitempty"`
+	Tags             []string          `yaml:"usage_tags,omitempty"`
+	Metadata         map[string]string `yaml:"metadata,omitempty"`
 }
 
 type deployment struct {
@@ -52,11 +74,14 @@ type host struct {
 	IpRanges         string            `yaml:"address_ranges"`
 	Username         string            `yaml:"username"`
 	Password         string            `yaml:"password"`
-	AvailabilityZone string            `yaml:"availability_zone"`
-	Tags             []string          `yaml:"usage_tags"`
-	Metadata         map[string]string `yaml:"metadata"`
+	AvailabilityZone string            `yaml:"availability_zone,omitempty"`
+	Tags             []string          `yaml:"usage_tags,omitempty"`
+	Metadata         map[string]string `yaml:"metadata,omitempty"`
 }
 
+// Loads a DC map, following its "include" list and applying its "defaults" block.
+// The returned installation is flattened: every host entry carries its own values
+// and Include/Defaults are empty.
 func LoadInstallation(file string) (res *Installation, err error) {
 	buf, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -67,9 +92,193 @@ func LoadInstallation(file string) (res *Installation, err error) {
 	if err != nil {
 		return nil, err
 	}
+
+	visited := map[string]bool{}
+	if abs, err := filepath.Abs(file); err == nil {
+		visited[abs] = true
+	}
+	hosts := mergeHosts(res.Defaults, res.Hosts)
+	included, err := loadIncludes(filepath.Dir(file), res.Include, res.Defaults, visited)
+	if err != nil {
+		return nil, err
+	}
+	res.Hosts = append(hosts, included...)
+	res.Include = nil
+	res.Defaults = hostDefaults{}
+	err = res.resolvePasswords()
+	if err != nil {
+		return nil, err
+	}
 	return
 }
 
+// Adds a host entry to the installation
+func (inst *Installation) AddHost(ipRanges string, username string, password string, availabilityZone string,
+	tags []string, metadata map[string]string) {
+	inst.Hosts = append(inst.Hosts, host{
+		IpRanges:         ipRanges,
+		Username:         username,
+		Password:         password,
+		AvailabilityZone: availabilityZone,
+		Tags:             tags,
+		Metadata:         metadata,
+	})
+}
+
+// Password placeholder, as written by "deployment export-dcmap"
+var passwordPlaceholder = regexp.MustCompile(`^\$\{(\w+)\}$`)
+
+// Replaces the passwords printed by MaskPasswords
+const passwordMask = "********"
+
+// Passwords of the form ${NAME} are read from the environment variable NAME, which must be set
+func (inst *Installation) resolvePasswords() error {
+	resolve := func(password *string, field string) error {
+		match := passwordPlaceholder.FindStringSubmatch(*password)
+		if match == nil {
+			return nil
+		}
+		value, present := os.LookupEnv(match[1])
+		if !present {
+			return fmt.Errorf("Environment variable %s for %s is not set", match[1], field)
+		}
+		*password = value
+		return nil
+	}
+
+	for i := range inst.Hosts {
+		err := resolve(&inst.Hosts[i].Password, fmt.Sprintf("the password of hosts '%s'", inst.Hosts[i].IpRanges))
+		if err != nil {
+			return err
+		}
+	}
+	err := resolve(&inst.Deployment.AuthPassword, "oauth_password")
+	if err != nil {
+		return err
+	}
+	return resolve(&inst.Deployment.NetworkManagerPassword, "network_manager_password")
+}
+
+// Replaces the passwords that are set, so that the installation can be printed
+func (inst *Installation) MaskPasswords() {
+	mask := func(password *string) {
+		if len(*password) != 0 {
+			*password = passwordMask
+		}
+	}
+	for i := range inst.Hosts {
+		mask(&inst.Hosts[i].Password)
+	}
+	mask(&inst.Deployment.AuthPassword)
+	mask(&inst.Deployment.NetworkManagerPassword)
+}
+
+// Returns the merged YAML form of an installation, as accepted by LoadInstallation
+func RenderInstallation(inst *Installation) ([]byte, error) {
+	return yaml.Marshal(inst)
+}
+
+func loadIncludes(dir string, patterns []string, defaults hostDefaults, visited map[string]bool) ([]host, error) {
+	var hosts []host
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("Included file '%s' not found", pattern)
+		}
+
+		for _, file := range files {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return nil, err
+			}
+			if visited[abs] {
+				return nil, fmt.Errorf("File '%s' is included more than once", file)
+			}
+			visited[abs] = true
+
+			buf, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			group := &hostGroup{}
+			err = yaml.Unmarshal(buf, group)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+
+			groupDefaults := mergeDefaults(defaults, group.Defaults)
+			hosts = append(hosts, mergeHosts(groupDefaults, group.Hosts)...)
+
+			nested, err := loadIncludes(filepath.Dir(file), group.Include, groupDefaults, visited)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, nested...)
+		}
+	}
+	return hosts, nil
+}
+
+// Layers the overrides on top of the base defaults. Metadata maps are merged key by key.
+func mergeDefaults(base hostDefaults, overrides hostDefaults) hostDefaults {
+	res := hostDefaults{
+		Username:         firstNonEmpty(overrides.Username, base.Username),
+		Password:         firstNonEmpty(overrides.Password, base.Password),
+		AvailabilityZone: firstNonEmpty(overrides.AvailabilityZone, base.AvailabilityZone),
+		Tags:             base.Tags,
+		Metadata:         mergeMetadata(base.Metadata, overrides.Metadata),
+	}
+	if overrides.Tags != nil {
+		res.Tags = overrides.Tags
+	}
+	return res
+}
+
+// Fills in every value a host entry leaves unset from the defaults
+func mergeHosts(defaults hostDefaults, hosts []host) []host {
+	var res []host
+	for _, h := range hosts {
+		h.Username = firstNonEmpty(h.Username, defaults.Username)
+		h.Password = firstNonEmpty(h.Password, defaults.Password)
+		h.AvailabilityZone = firstNonEmpty(h.AvailabilityZone, defaults.AvailabilityZone)
+		if h.Tags == nil {
+			h.Tags = defaults.Tags
+		}
+		h.Metadata = mergeMetadata(defaults.Metadata, h.Metadata)
+		res = append(res, h)
+	}
+	return res
+}
+
+func mergeMetadata(base map[string]string, overrides map[string]string) map[string]string {
+	if len(base) == 0 {
+		return overrides
+	}
+	res := make(map[string]string)
+	for key, value := range base {
+		res[key] = value
+	}
+	for key, value := range overrides {
+		res[key] = value
+	}
+	return res
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
 type imageDatastores []string
 
 func (d *imageDatastores) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
@@ -16,6 +16,7 @@ import (
 	. "github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/onsi/gomega"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 var _ = Describe("Installation", func() {
@@ -171,5 +172,174 @@ deployment:
 				})
 			})
 		})
+
+		Describe("defaults", func() {
+			BeforeEach(func() {
+				fileContent = `---
+defaults:
+  username: root
+  password: secret
+  availability_zone: az1
+  usage_tags:
+  - CLOUD
+  metadata:
+    ALLOWED_DATASTORES: ds1
+    MANAGEMENT_DATASTORE: ds1
+hosts:
+  - address_ranges: 10.0.0.1
+  - address_ranges: 10.0.0.2
+    password: other
+    availability_zone: az2
+    usage_tags:
+    - MGMT
+    metadata:
+      MANAGEMENT_DATASTORE: ds2
+`
+			})
+
+			It("applies defaults and keeps host overrides", func() {
+				inst, err := LoadInstallation(file.Name())
+				Expect(err).To(BeNil())
+				Expect(inst.Hosts).To(HaveLen(2))
+
+				Expect(inst.Hosts[0].Username).To(Equal("root"))
+				Expect(inst.Hosts[0].Password).To(Equal("secret"))
+				Expect(inst.Hosts[0].AvailabilityZone).To(Equal("az1"))
+				Expect(inst.Hosts[0].Tags).To(Equal([]string{"CLOUD"}))
+				Expect(inst.Hosts[0].Metadata).To(Equal(map[string]string{
+					"ALLOWED_DATASTORES": "ds1", "MANAGEMENT_DATASTORE": "ds1"}))
+
+				Expect(inst.Hosts[1].Username).To(Equal("root"))
+				Expect(inst.Hosts[1].Password).To(Equal("other"))
+				Expect(inst.Hosts[1].AvailabilityZone).To(Equal("az2"))
+				Expect(inst.Hosts[1].Tags).To(Equal([]string{"MGMT"}))
+				Expect(inst.Hosts[1].Metadata).To(Equal(map[string]string{
+					"ALLOWED_DATASTORES": "ds1", "MANAGEMENT_DATASTORE": "ds2"}))
+			})
+
+			It("renders without the defaults block", func() {
+				inst, err := LoadInstallation(file.Name())
+				Expect(err).To(BeNil())
+
+				out, err := RenderInstallation(inst)
+				Expect(err).To(BeNil())
+				Expect(string(out)).ToNot(ContainSubstring("defaults:"))
+				Expect(string(out)).To(ContainSubstring("password: other"))
+			})
+		})
+
+		Describe("password placeholders", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  oauth_password: ${TEST_OAUTH_PASSWORD}
+hosts:
+  - address_ranges: 10.0.0.1
+    password: ${TEST_ESX_PASSWORD}
+  - address_ranges: 10.0.0.2
+    password: pa${TEST_ESX_PASSWORD}
+`
+				_ = os.Setenv("TEST_ESX_PASSWORD", "secret")
+				_ = os.Unsetenv("TEST_OAUTH_PASSWORD")
+			})
+
+			AfterEach(func() {
+				_ = os.Unsetenv("TEST_ESX_PASSWORD")
+			})
+
+			It("reads passwords from the environment", func() {
+				_ = os.Setenv("TEST_OAUTH_PASSWORD", "oauth-secret")
+				defer os.Unsetenv("TEST_OAUTH_PASSWORD")
+
+				inst, err := LoadInstallation(file.Name())
+				Expect(err).To(BeNil())
+				Expect(inst.Hosts[0].Password).To(Equal("secret"))
+				Expect(inst.Hosts[1].Password).To(Equal("pa${TEST_ESX_PASSWORD}"))
+				Expect(inst.Deployment.AuthPassword).To(Equal("oauth-secret"))
+			})
+
+			It("fails when the environment variable is not set", func() {
+				_, err := LoadInstallation(file.Name())
+				Expect(err).ToNot(BeNil())
+				Expect(err.Error()).To(ContainSubstring("TEST_OAUTH_PASSWORD"))
+			})
+		})
+
+		Describe("include", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "installation_include_")
+				if err != nil {
+					Fail("Could not create temporary test directory.")
+				}
+				err = ioutil.WriteFile(filepath.Join(dir, "rack1.yml"), []byte(`---
+defaults:
+  availability_zone: rack1
+hosts:
+  - address_ranges: 10.0.1.1-10.0.1.2
+`), 0644)
+				if err != nil {
+					Fail("Could not write included test file.")
+				}
+				err = ioutil.WriteFile(filepath.Join(dir, "rack2.yml"), []byte(`---
+hosts:
+  - address_ranges: 10.0.2.1
+    username: admin
+`), 0644)
+				if err != nil {
+					Fail("Could not write included test file.")
+				}
+			})
+
+			AfterEach(func() {
+				_ = os.RemoveAll(dir)
+			})
+
+			Context("when included files exist", func() {
+				BeforeEach(func() {
+					fileContent = `---
+include:
+  - ` + filepath.Join(dir, "rack*.yml") + `
+defaults:
+  username: root
+  password: secret
+hosts:
+  - address_ranges: 10.0.0.1
+`
+				})
+
+				It("merges hosts from every file", func() {
+					inst, err := LoadInstallation(file.Name())
+					Expect(err).To(BeNil())
+					Expect(inst.Include).To(BeNil())
+					Expect(inst.Hosts).To(HaveLen(3))
+
+					Expect(inst.Hosts[0].IpRanges).To(Equal("10.0.0.1"))
+					Expect(inst.Hosts[1].IpRanges).To(Equal("10.0.1.1-10.0.1.2"))
+					Expect(inst.Hosts[1].Username).To(Equal("root"))
+					Expect(inst.Hosts[1].AvailabilityZone).To(Equal("rack1"))
+					Expect(inst.Hosts[2].Username).To(Equal("admin"))
+					Expect(inst.Hosts[2].Password).To(Equal("secret"))
+					Expect(inst.Hosts[2].AvailabilityZone).To(Equal(""))
+				})
+			})
+
+			Context("when an included file is missing", func() {
+				BeforeEach(func() {
+					fileContent = `---
+include:
+  - ` + filepath.Join(dir, "missing.yml") + `
+`
+				})
+
+				It("fails to load file", func() {
+					inst, err := LoadInstallation(file.Name())
+					Expect(err).ToNot(BeNil())
+					Expect(inst).To(BeNil())
+				})
+			})
+		})
 	})
 })
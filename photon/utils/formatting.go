@@ -13,7 +13,7 @@ package utils
  * These utilities format output in a variety of ways.
  *
  * The goal is to have multiple methods of output so that it's easy to script the CLI
- * in whatever way a user wants. Currently we just implement JSON output, but we plan
+ * in whatever way a user wants. Currently we implement JSON and CSV output, but we plan
  * to implement a subset of the JSONPath spec so that we can implement:
  * - output just a single value (e.g. ID) from an object
  * - output a list of objects as a table with the columns specified by the user
@@ -25,10 +25,13 @@ package utils
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/vmware/photon-controller-cli/Godeps/_workspace/src/github.com/codegangsta/cli"
 )
@@ -40,8 +43,8 @@ func ValidateArgs(c *cli.Context) error {
 	if c.GlobalBool("non-interactive") == true && c.GlobalString("output") != "" {
 		return fmt.Errorf("--non-interactive and --output are mutually exclusive")
 	}
-	if c.GlobalString("output") != "" && c.GlobalString("output") != "json" {
-		return fmt.Errorf("output type must be 'json'")
+	if c.GlobalString("output") != "" && c.GlobalString("output") != "json" && c.GlobalString("output") != "csv" {
+		return fmt.Errorf("output type must be 'json' or 'csv'")
 	}
 	return nil
 }
@@ -63,12 +66,14 @@ func NeedsFormatting(c *cli.Context) bool {
 }
 
 // Outputs the given object (image, list of images, VM, etc...) as specified by the user
-// Currently we only support JSON output, but we will support more formats later.
+// JSON and CSV output are supported.
 func FormatObject(o interface{}, w io.Writer, c *cli.Context) {
 	outputType := c.GlobalString("output")
 	switch outputType {
 	case "json":
 		formatObjectJson(o, w)
+	case "csv":
+		formatObjectCsv(o, w)
 	default:
 		fmt.Fprintf(w, "Unknown output type: '%s'", outputType)
 	}
@@ -103,3 +108,196 @@ func formatObjectJson(o interface{}, w io.Writer) {
 	}
 	fmt.Fprintf(w, "%s\n", string(prettyJSON.Bytes()))
 }
+
+// Output a struct or a list of structs as CSV, with one row per struct
+// The column names are the JSON field names. Lists are joined with ',' and
+// maps are written as comma separated key=value pairs. Fields holding structs are not
+// flattened, they are written as a JSON blob in a single column, so CSV is mostly
+// useful for list commands.
+func formatObjectCsv(o interface{}, w io.Writer) {
+	value := reflect.Indirect(reflect.ValueOf(o))
+	var rows []reflect.Value
+	if value.Kind() == reflect.Array || value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, reflect.Indirect(value.Index(i)))
+		}
+	} else {
+		rows = append(rows, value)
+	}
+
+	writer := csv.NewWriter(w)
+	if len(rows) == 0 {
+		writer.Flush()
+		return
+	}
+	if rows[0].Kind() != reflect.Struct {
+		fmt.Fprintf(w, "Cannot convert output to CSV: %s is not a struct", rows[0].Type())
+		return
+	}
+
+	header, fields := getCsvColumns(rows[0].Type(), nil)
+	err := writer.Write(header)
+	for _, row := range rows {
+		if err != nil {
+			break
+		}
+		var record []string
+		for _, index := range fields {
+			record = append(record, formatCsvValue(row.FieldByIndex(index)))
+		}
+		err = writer.Write(record)
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Cannot write CSV output: %s", err)
+	}
+}
+
+// Returns the column names and field indexes of a struct. Like in JSON, the fields
+// of embedded structs are promoted.
+func getCsvColumns(rowType reflect.Type, parent []int) (header []string, fields [][]int) {
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		index := append(append([]int{}, parent...), i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embeddedHeader, embeddedFields := getCsvColumns(field.Type, index)
+			header = append(header, embeddedHeader...)
+			fields = append(fields, embeddedFields...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		header = append(header, name)
+		fields = append(fields, index)
+	}
+	return
+}
+
+func formatCsvValue(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return ""
+		}
+		return formatCsvValue(value.Elem())
+	case reflect.Array, reflect.Slice:
+		var items []string
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, formatCsvValue(value.Index(i)))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		var items []string
+		for _, key := range value.MapKeys() {
+			items = append(items, fmt.Sprintf("%v=%s", key.Interface(), formatCsvValue(value.MapIndex(key))))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	case reflect.Struct:
+		jsonBytes, err := json.Marshal(value.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(jsonBytes)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// Writes a list in the output format requested by the user as it is fetched, a few items
+// at a time, so that the whole list never has to be held in memory. The output is the
+// same as the one of FormatObjects for the complete list.
+type ListWriter struct {
+	w         io.Writer
+	format    string
+	count     int
+	csvWriter *csv.Writer
+	csvFields [][]int
+}
+
+func NewListWriter(w io.Writer, c *cli.Context) *ListWriter {
+	return &ListWriter{w: w, format: c.GlobalString("output")}
+}
+
+// Writes the items of a slice
+func (lw *ListWriter) Write(items interface{}) error {
+	value := reflect.ValueOf(items)
+	for i := 0; i < value.Len(); i++ {
+		err := lw.writeItem(reflect.Indirect(value.Index(i)))
+		if err != nil {
+			return err
+		}
+		lw.count++
+	}
+	return nil
+}
+
+func (lw *ListWriter) writeItem(item reflect.Value) error {
+	switch lw.format {
+	case "json":
+		jsonBytes, err := json.Marshal(item.Interface())
+		if err != nil {
+			return fmt.Errorf("Cannot convert output to JSON: %s", err)
+		}
+		var prettyJSON bytes.Buffer
+		err = json.Indent(&prettyJSON, jsonBytes, "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("Cannot format JSON output: %s", err)
+		}
+		separator := ",\n  "
+		if lw.count == 0 {
+			separator = "[\n  "
+		}
+		_, err = fmt.Fprintf(lw.w, "%s%s", separator, prettyJSON.String())
+		return err
+	case "csv":
+		if item.Kind() != reflect.Struct {
+			return fmt.Errorf("Cannot convert output to CSV: %s is not a struct", item.Type())
+		}
+		if lw.csvWriter == nil {
+			var header []string
+			header, lw.csvFields = getCsvColumns(item.Type(), nil)
+			lw.csvWriter = csv.NewWriter(lw.w)
+			err := lw.csvWriter.Write(header)
+			if err != nil {
+				return err
+			}
+		}
+		var record []string
+		for _, index := range lw.csvFields {
+			record = append(record, formatCsvValue(item.FieldByIndex(index)))
+		}
+		err := lw.csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+		lw.csvWriter.Flush()
+		return lw.csvWriter.Error()
+	default:
+		return fmt.Errorf("Unknown output type: '%s'", lw.format)
+	}
+}
+
+// Ends the list, it must be called once every item has been written
+func (lw *ListWriter) Close() error {
+	if lw.format != "json" {
+		return nil
+	}
+	end := "\n]\n"
+	if lw.count == 0 {
+		end = "[]\n"
+	}
+	_, err := fmt.Fprint(lw.w, end)
+	return err
+}